@@ -0,0 +1,162 @@
+package yubikeyotp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials identify a client of the Yubico validation API.
+// Obtain them from Yubi Corporation: <https://upgrade.yubico.com/getapikey/>.
+type Credentials struct {
+	// ClientID identifies the client.
+	ClientID uint
+	// Secret is the decoded API key for signing requests.
+	Secret []byte
+}
+
+// CredentialProvider looks up API client credentials by tenant,
+// so that secrets do not have to travel with each [Request].
+type CredentialProvider interface {
+	Credentials(ctx context.Context, tenant string) (Credentials, error)
+}
+
+var (
+	_ CredentialProvider = StaticCredentials(nil)
+	_ CredentialProvider = EnvironmentCredentials("")
+	_ CredentialProvider = (*credentialCache)(nil)
+)
+
+// StaticCredentials satisfies the [CredentialProvider] interface
+// using credentials indexed by tenant.
+type StaticCredentials map[string]Credentials
+
+func (s StaticCredentials) Credentials(_ context.Context, tenant string) (Credentials, error) {
+	c, ok := s[tenant]
+	if !ok {
+		return Credentials{}, fmt.Errorf("tenant %q: %w", tenant, ErrRequestClientDoesNotExist)
+	}
+	return c, nil
+}
+
+// EnvironmentCredentials satisfies the [CredentialProvider] interface
+// by reading environment variables that start with the prefix.
+// For prefix "YUBIKEY_" and tenant "acme", the client ID is read from
+// YUBIKEY_ACME_CLIENT_ID and the base64 API key from YUBIKEY_ACME_CLIENT_SECRET.
+// Empty tenant reads YUBIKEY_CLIENT_ID and YUBIKEY_CLIENT_SECRET.
+// Tenant names may contain only letters, digits, and underscores
+// and are not case sensitive.
+type EnvironmentCredentials string
+
+func (e EnvironmentCredentials) Credentials(_ context.Context, tenant string) (Credentials, error) {
+	prefix := string(e)
+	if tenant != "" {
+		for _, c := range []byte(tenant) {
+			if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_') {
+				// mapping such characters would let different tenants share credentials
+				return Credentials{}, fmt.Errorf("tenant %q cannot be read from environment variables: only letters, digits, and underscores are allowed", tenant)
+			}
+		}
+		prefix += strings.ToUpper(tenant) + "_"
+	}
+	clientID := strings.TrimSpace(os.Getenv(prefix + "CLIENT_ID"))
+	secret := strings.TrimSpace(os.Getenv(prefix + "CLIENT_SECRET"))
+	if clientID == "" || secret == "" {
+		return Credentials{}, fmt.Errorf("tenant %q: environment variables %sCLIENT_ID and %sCLIENT_SECRET are not set: %w", tenant, prefix, prefix, ErrRequestClientDoesNotExist)
+	}
+	return decodeCredentials(clientID, secret)
+}
+
+// FileCredentials satisfies the [CredentialProvider] interface using
+// credentials loaded from a JSON file. Create only with [NewFileCredentials].
+type FileCredentials struct {
+	credentials StaticCredentials
+}
+
+// NewFileCredentials loads credentials from a JSON file that maps
+// tenants to client identifiers and base64 API keys:
+//
+//	{"acme": {"clientID": 12345, "secret": "c2VjcmV0IGtleQ=="}}
+func NewFileCredentials(path string) (*FileCredentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read credentials: %w", err)
+	}
+	var entries map[string]struct {
+		ClientID uint   `json:"clientID"`
+		Secret   string `json:"secret"`
+	}
+	if err = json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("unable to decode credentials file %q: %w", path, err)
+	}
+	f := &FileCredentials{credentials: make(StaticCredentials, len(entries))}
+	for tenant, entry := range entries {
+		if f.credentials[tenant], err = decodeCredentials(strconv.FormatUint(uint64(entry.ClientID), 10), entry.Secret); err != nil {
+			return nil, fmt.Errorf("invalid credentials of tenant %q: %w", tenant, err)
+		}
+	}
+	return f, nil
+}
+
+func (f *FileCredentials) Credentials(ctx context.Context, tenant string) (Credentials, error) {
+	return f.credentials.Credentials(ctx, tenant)
+}
+
+func decodeCredentials(clientID, secret string) (c Credentials, err error) {
+	id, err := strconv.ParseUint(clientID, 10, 0)
+	if err != nil || id == 0 {
+		return c, fmt.Errorf("invalid client ID %q", clientID)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return c, fmt.Errorf("invalid client secret: %w", err)
+	}
+	if len(decoded) == 0 {
+		return c, errors.New("client secret is empty")
+	}
+	return Credentials{ClientID: uint(id), Secret: decoded}, nil
+}
+
+// credentialCache remembers credentials returned by a provider
+// for a limited time to avoid repeated lookups and decoding.
+type credentialCache struct {
+	provider CredentialProvider
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cachedCredentials
+}
+
+type cachedCredentials struct {
+	Credentials Credentials
+	ExpiresAt   time.Time
+}
+
+func (c *credentialCache) Credentials(ctx context.Context, tenant string) (Credentials, error) {
+	now := time.Now()
+	c.mu.Lock()
+	cached, ok := c.entries[tenant]
+	c.mu.Unlock()
+	if ok && now.Before(cached.ExpiresAt) {
+		return cached.Credentials, nil
+	}
+
+	credentials, err := c.provider.Credentials(ctx, tenant)
+	if err != nil {
+		return Credentials{}, err
+	}
+	c.mu.Lock()
+	c.entries[tenant] = cachedCredentials{
+		Credentials: credentials,
+		ExpiresAt:   now.Add(c.ttl),
+	}
+	c.mu.Unlock()
+	return credentials, nil
+}
@@ -0,0 +1,92 @@
+package yubikeyotp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCredentialProviders(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString(stubClientSecret)
+	t.Setenv("TEST_YUBIKEY_ACME_CORP_CLIENT_ID", "87")
+	t.Setenv("TEST_YUBIKEY_ACME_CORP_CLIENT_SECRET", secret)
+
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, []byte(`{"acme_corp": {"clientID": 87, "secret": "`+secret+`"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := NewFileCredentials(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, provider := range map[string]CredentialProvider{
+		"static": StaticCredentials{"acme_corp": {ClientID: 87, Secret: stubClientSecret}},
+		"env":    EnvironmentCredentials("TEST_YUBIKEY_"),
+		"file":   file,
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, err := provider.Credentials(t.Context(), "acme_corp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.ClientID != 87 || !bytes.Equal(c.Secret, stubClientSecret) {
+				t.Errorf("unexpected credentials: %+v", c)
+			}
+			if _, err = provider.Credentials(t.Context(), "unknown"); !errors.Is(err, ErrRequestClientDoesNotExist) {
+				t.Errorf("expected error %v, got %v", ErrRequestClientDoesNotExist, err)
+			}
+		})
+	}
+}
+
+func TestEnvironmentCredentialsRejectTenantNames(t *testing.T) {
+	t.Setenv("TEST_YUBIKEY_ACME_CORP_CLIENT_ID", "87")
+	t.Setenv("TEST_YUBIKEY_ACME_CORP_CLIENT_SECRET", base64.StdEncoding.EncodeToString(stubClientSecret))
+	for _, tenant := range []string{"acme-corp", "acme.corp", "acme corp", "acme_corp\x00", "ácme_corp"} {
+		if _, err := EnvironmentCredentials("TEST_YUBIKEY_").Credentials(t.Context(), tenant); err == nil {
+			t.Errorf("tenant %q shares credentials of tenant %q", tenant, "acme_corp")
+		}
+	}
+}
+
+type countingCredentialProvider struct {
+	CredentialProvider
+	Calls int
+}
+
+func (p *countingCredentialProvider) Credentials(ctx context.Context, tenant string) (Credentials, error) {
+	p.Calls++
+	return p.CredentialProvider.Credentials(ctx, tenant)
+}
+
+func TestAuthenticationWithCredentialProvider(t *testing.T) {
+	provider := &countingCredentialProvider{
+		CredentialProvider: StaticCredentials{"acme_corp": {ClientID: stubClientID, Secret: stubClientSecret}},
+	}
+	authenticator, err := New(
+		WithEndpoints(startStubServer(t, echoFields)),
+		WithCredentialProvider(provider),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := Request{OneTimePassword: stubOneTimePassword, Tenant: "acme_corp"}
+	for range 3 {
+		if err = authenticator.Authenticate(t.Context(), request); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if provider.Calls != 1 {
+		t.Errorf("expected credentials to be cached, provider was called %d times", provider.Calls)
+	}
+
+	request.Tenant = "unknown"
+	if err = authenticator.Authenticate(t.Context(), request); !errors.Is(err, ErrRequestClientDoesNotExist) {
+		t.Errorf("expected error %v, got %v", ErrRequestClientDoesNotExist, err)
+	}
+}
@@ -1,8 +1,9 @@
 package yubikeyotp
 
 import (
-	"context"
-	"net/http"
+	"math"
+	"slices"
+	"sync"
 	"time"
 )
 
@@ -15,51 +16,255 @@ var DefaultEndpoints = []string{
 	"https://api5.yubico.com/wsapi/2.0/verify",
 }
 
+// signedQuery is a request prepared for validation servers
+// together with the values required to verify their responses.
+type signedQuery struct {
+	Query           string
+	Secret          []byte
+	Nonce           Nonce
+	OneTimePassword string
+	// Request without the client secret for [Hooks].
+	Request Request
+}
+
+// GetCurrentEndpoint returns the endpoint that the next request will be sent to.
 func (a *Authenticator) GetCurrentEndpoint() string {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-	return a.endpoints[a.currentEndpointIndex]
+	return a.endpoints.Pick(nil, false)
+}
+
+// EndpointStatus returns observed health of each configured endpoint.
+func (a *Authenticator) EndpointStatus() []EndpointStatus {
+	return a.endpoints.Status()
+}
+
+// CircuitState indicates whether requests are sent to an endpoint.
+type CircuitState uint8
+
+const (
+	// CircuitClosed endpoint is healthy and receives requests.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen endpoint failed repeatedly and is ejected for a cool-down period.
+	CircuitOpen
+	// CircuitHalfOpen endpoint finished its cool-down period and may receive a probe request.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// EndpointStatus describes observed health of an API endpoint.
+type EndpointStatus struct {
+	URL                 string
+	State               CircuitState
+	Successes           uint64
+	Failures            uint64
+	ConsecutiveFailures int
+	// Latency is the exponentially weighted moving average of response times.
+	Latency time.Duration
+	// EjectedUntil is the end of the cool-down period of an open circuit.
+	EjectedUntil time.Time
+}
+
+// endpointPool tracks health of endpoints, ejects failing ones
+// for a cool-down period, and prefers the fastest healthy endpoint.
+type endpointPool struct {
+	failureThreshold int
+	coolDown         time.Duration
+
+	mu        sync.Mutex
+	endpoints []EndpointStatus
+	// latencies is a ring buffer of recent successful response times
+	// across all endpoints for estimating latency percentiles.
+	latencies     [128]time.Duration
+	latencyCursor int
+	latencyCount  int
+}
+
+// minimumLatencySamples is the number of observations required
+// before latency percentiles are considered meaningful.
+const minimumLatencySamples = 16
+
+func newEndpointPool(urls []string, failureThreshold int, coolDown time.Duration) *endpointPool {
+	p := &endpointPool{
+		failureThreshold: failureThreshold,
+		coolDown:         coolDown,
+		endpoints:        make([]EndpointStatus, len(urls)),
+	}
+	for i, url := range urls {
+		p.endpoints[i].URL = url
+	}
+	return p
+}
+
+func (p *endpointPool) state(e *EndpointStatus, now time.Time) CircuitState {
+	if e.ConsecutiveFailures < p.failureThreshold {
+		return CircuitClosed
+	}
+	if now.Before(e.EjectedUntil) {
+		return CircuitOpen
+	}
+	return CircuitHalfOpen
+}
+
+// Pick returns the endpoint for the next attempt skipping the excluded ones.
+// Half-open endpoints are probed first; reserving a probe keeps other
+// requests away from the endpoint until the probe is reported. Otherwise,
+// the healthy endpoint with the lowest latency wins, preferring those that
+// have not failed since their last success. If every endpoint is ejected,
+// the one that recovers soonest is returned.
+func (p *endpointPool) Pick(exclude []string, reserve bool) string {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if url := p.pick(exclude, reserve, now); url != "" || len(exclude) == 0 {
+		return url
+	}
+	// every endpoint was already tried, start over
+	return p.pick(nil, reserve, now)
 }
 
-func (a *Authenticator) rotateEndpoint() string {
-	a.mu.Lock()
-	defer a.mu.Unlock()
+func (p *endpointPool) pick(exclude []string, reserve bool, now time.Time) string {
+	var fastest, soonest *EndpointStatus
+	for i := range p.endpoints {
+		e := &p.endpoints[i]
+		if slices.Contains(exclude, e.URL) {
+			continue
+		}
+		switch p.state(e, now) {
+		case CircuitHalfOpen:
+			if reserve {
+				e.EjectedUntil = now.Add(p.coolDown)
+			}
+			return e.URL
+		case CircuitClosed:
+			if fastest == nil || preferable(e, fastest) {
+				fastest = e
+			}
+		case CircuitOpen:
+			if soonest == nil || e.EjectedUntil.Before(soonest.EjectedUntil) {
+				soonest = e
+			}
+		}
+	}
+	switch {
+	case fastest != nil:
+		return fastest.URL
+	case soonest != nil:
+		return soonest.URL
+	default:
+		return ""
+	}
+}
 
-	a.currentEndpointIndex++
-	if total := len(DefaultEndpoints); a.currentEndpointIndex >= total {
-		a.currentEndpointIndex = 0
+// preferable returns true if the closed endpoint should be picked over the other one.
+// Latency only reflects successful responses, so an endpoint that is failing
+// fast must not look faster than a healthy one.
+func preferable(e, other *EndpointStatus) bool {
+	if failing, otherFailing := e.ConsecutiveFailures > 0, other.ConsecutiveFailures > 0; failing != otherFailing {
+		return otherFailing
 	}
-	return a.endpoints[a.currentEndpointIndex]
+	return e.Latency < other.Latency
 }
 
-func (a *Authenticator) sendQuery(
-	ctx context.Context,
-	query string,
-) (response *http.Response, err error) {
-	client := a.clientPool.Get().(*http.Client)
-	defer a.clientPool.Put(client)
+// Len returns the number of endpoints.
+func (p *endpointPool) Len() int {
+	return len(p.endpoints)
+}
 
-	delay := a.retryBackoffDelay
-	endpoint := a.GetCurrentEndpoint()
+// Available returns endpoints that are not ejected.
+// If every endpoint is ejected, all of them are returned.
+func (p *endpointPool) Available() []string {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	for range a.retryLimit {
-		request, err := http.NewRequest("GET", endpoint+"?"+query, nil)
-		if err != nil {
-			return nil, err
+	available := make([]string, 0, len(p.endpoints))
+	for i := range p.endpoints {
+		if p.state(&p.endpoints[i], now) != CircuitOpen {
+			available = append(available, p.endpoints[i].URL)
 		}
-		response, err = client.Do(request.WithContext(ctx))
-		if err == nil {
-			return response, nil
+	}
+	if len(available) == 0 {
+		for _, e := range p.endpoints {
+			available = append(available, e.URL)
 		}
-		// TODO: errors.Join or log the attempt error somewhere?
-
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-time.After(delay):
-			delay *= a.retryBackoffMultiplier
-			endpoint = a.rotateEndpoint()
+	}
+	return available
+}
+
+// Report records the outcome of an attempt. An endpoint that fails
+// failure threshold times in a row is ejected for the cool-down period.
+func (p *endpointPool) Report(url string, latency time.Duration, failed bool) {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := range p.endpoints {
+		e := &p.endpoints[i]
+		if e.URL != url {
+			continue
+		}
+		if failed {
+			e.Failures++
+			e.ConsecutiveFailures++
+			if e.ConsecutiveFailures >= p.failureThreshold {
+				e.EjectedUntil = now.Add(p.coolDown)
+			}
+			return
+		}
+		p.latencies[p.latencyCursor] = latency
+		p.latencyCursor = (p.latencyCursor + 1) % len(p.latencies)
+		p.latencyCount = min(p.latencyCount+1, len(p.latencies))
+
+		e.Successes++
+		e.ConsecutiveFailures = 0
+		e.EjectedUntil = time.Time{}
+		if e.Latency == 0 {
+			e.Latency = latency
+		} else {
+			// moving average with smoothing factor of 1/5
+			e.Latency += (latency - e.Latency) / 5
 		}
+		return
+	}
+}
+
+// LatencyPercentile returns the percentile of recently observed response times.
+// Returns false until enough responses were observed.
+func (p *endpointPool) LatencyPercentile(percentile float64) (time.Duration, bool) {
+	p.mu.Lock()
+	samples := slices.Clone(p.latencies[:p.latencyCount])
+	p.mu.Unlock()
+
+	if len(samples) < minimumLatencySamples {
+		return 0, false
+	}
+	slices.Sort(samples)
+	index := int(math.Ceil(percentile/100*float64(len(samples)))) - 1
+	return samples[max(index, 0)], true
+}
+
+// Status returns a snapshot of endpoint health.
+func (p *endpointPool) Status() []EndpointStatus {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := make([]EndpointStatus, len(p.endpoints))
+	for i, e := range p.endpoints {
+		e.State = p.state(&e, now)
+		status[i] = e
 	}
-	return nil, err
+	return status
 }
@@ -0,0 +1,238 @@
+package yubikeyotp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEndpointPoolPrefersFastestHealthyEndpoint(t *testing.T) {
+	pool := newEndpointPool([]string{"a", "b", "c"}, 2, time.Millisecond*20)
+	pool.Report("a", time.Millisecond*30, false)
+	pool.Report("b", time.Millisecond*10, false)
+	pool.Report("c", time.Millisecond*20, false)
+	if picked := pool.Pick(nil, true); picked != "b" {
+		t.Fatalf("expected the fastest endpoint, got %q", picked)
+	}
+	if picked := pool.Pick([]string{"b"}, true); picked != "c" {
+		t.Fatalf("expected the second fastest endpoint, got %q", picked)
+	}
+
+	pool.Report("b", 0, true)
+	if state := pool.Status()[1].State; state != CircuitClosed {
+		t.Fatalf("endpoint was ejected before reaching the failure threshold")
+	}
+	if picked := pool.Pick(nil, true); picked != "c" {
+		t.Fatalf("endpoint that failed since its last success is preferred, got %q", picked)
+	}
+	if picked := pool.Pick([]string{"a", "c"}, true); picked != "b" {
+		t.Fatalf("failing endpoint below the failure threshold is not available, got %q", picked)
+	}
+	pool.Report("b", 0, true)
+	if picked := pool.Pick(nil, true); picked != "c" {
+		t.Fatalf("failing endpoint was not ejected, got %q", picked)
+	}
+	if state := pool.Status()[1].State; state != CircuitOpen {
+		t.Fatalf("expected open circuit, got %s", state)
+	}
+
+	time.Sleep(time.Millisecond * 25)
+	if state := pool.Status()[1].State; state != CircuitHalfOpen {
+		t.Fatalf("expected half-open circuit, got %s", state)
+	}
+	if picked := pool.Pick(nil, true); picked != "b" {
+		t.Fatalf("half-open endpoint was not probed, got %q", picked)
+	}
+	if picked := pool.Pick(nil, true); picked != "c" {
+		t.Fatalf("half-open endpoint received more than one probe")
+	}
+	pool.Report("b", time.Millisecond*10, false)
+	status := pool.Status()[1]
+	if status.State != CircuitClosed || status.Successes != 2 || status.Failures != 2 {
+		t.Fatalf("unexpected status after a successful probe: %+v", status)
+	}
+}
+
+func TestEndpointPoolWithAllEndpointsEjected(t *testing.T) {
+	pool := newEndpointPool([]string{"a", "b"}, 1, time.Minute)
+	pool.Report("a", 0, true)
+	time.Sleep(time.Millisecond)
+	pool.Report("b", 0, true)
+	if picked := pool.Pick(nil, true); picked != "a" {
+		t.Fatalf("expected the endpoint that recovers first, got %q", picked)
+	}
+	if available := pool.Available(); len(available) != 2 {
+		t.Fatalf("expected all endpoints to be available as a last resort, got %v", available)
+	}
+}
+
+func TestSignedStatusesDoNotCountAsEndpointFailures(t *testing.T) {
+	var reported []Attempt
+	authenticator, err := New(
+		WithEndpoints(
+			startStubServer(t, withStatus("NOT_ENOUGH_ANSWERS")),
+			startStubServer(t, withStatus("REPLAYED_REQUEST")),
+		),
+		WithRetryPolicy(RetryWithBackOff{
+			AttemptLimit:           2,
+			AttemptDelay:           time.Millisecond * 31,
+			AttemptDelayLimit:      time.Millisecond * 31,
+			AttemptDelayMultiplier: 2,
+		}),
+		WithHooks(Hooks{
+			OnEndpointFailure: func(_ context.Context, _ Request, attempt Attempt) {
+				reported = append(reported, attempt)
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = authenticator.Authenticate(t.Context(), stubRequest()); err == nil {
+		t.Fatal("expected validation to fail")
+	}
+	for _, status := range authenticator.EndpointStatus() {
+		if status.Failures != 0 {
+			t.Errorf("healthy endpoint %s counted %d failures", status.URL, status.Failures)
+		}
+	}
+	if len(reported) != 0 {
+		t.Errorf("endpoint failure hook was called for healthy endpoints: %v", reported)
+	}
+}
+
+func TestRetriesCycleThroughCustomEndpoints(t *testing.T) {
+	failing := func() string {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		t.Cleanup(server.Close)
+		return server.URL
+	}
+	authenticator, err := New(
+		WithEndpoints(failing(), failing()),
+		WithRetryPolicy(RetryWithBackOff{
+			AttemptLimit:           5,
+			AttemptDelay:           time.Millisecond * 31,
+			AttemptDelayLimit:      time.Millisecond * 31,
+			AttemptDelayMultiplier: 2,
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = authenticator.Authenticate(t.Context(), stubRequest()); !errors.Is(err, StatusCodeError(http.StatusServiceUnavailable)) {
+		t.Fatalf("expected service unavailable error, got %v", err)
+	}
+	for _, status := range authenticator.EndpointStatus() {
+		if status.Failures == 0 {
+			t.Errorf("endpoint %s was never tried", status.URL)
+		}
+	}
+}
+
+func TestEndpointPoolLatencyPercentile(t *testing.T) {
+	pool := newEndpointPool([]string{"a"}, 1, time.Minute)
+	if _, ok := pool.LatencyPercentile(90); ok {
+		t.Fatal("percentile is reported without observations")
+	}
+	for i := 1; i <= 100; i++ {
+		pool.Report("a", time.Duration(i)*time.Millisecond, false)
+	}
+	for _, tc := range []struct {
+		Percentile float64
+		Expected   time.Duration
+	}{
+		{50, time.Millisecond * 50},
+		{90, time.Millisecond * 90},
+		{99, time.Millisecond * 99},
+	} {
+		if latency, ok := pool.LatencyPercentile(tc.Percentile); !ok || latency != tc.Expected {
+			t.Errorf("percentile %.0f: expected %s, got %s", tc.Percentile, tc.Expected, latency)
+		}
+	}
+}
+
+func TestHedgedVerification(t *testing.T) {
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second * 5):
+		}
+	}))
+	t.Cleanup(stalled.Close)
+	fast := startStubServer(t, echoFields)
+
+	authenticator, err := New(
+		WithEndpoints(stalled.URL, fast),
+		WithHedging(Hedging{Delay: time.Millisecond * 50}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now()
+	result, err := authenticator.Verify(t.Context(), stubRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Endpoint != fast {
+		t.Errorf("expected answer from %s, got %s", fast, result.Endpoint)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("hedged request took too long: %s", elapsed)
+	}
+
+	replayed := startStubServer(t, withStatus("REPLAYED_OTP"))
+	authenticator, err = New(
+		WithEndpoints(replayed, fast),
+		WithHedging(Hedging{Delay: time.Millisecond * 50}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = authenticator.Authenticate(t.Context(), stubRequest()); !errors.Is(err, ErrRequestReplayed) {
+		t.Fatalf("expected error %v, got %v", ErrRequestReplayed, err)
+	}
+
+	if _, err = New(WithFanOut(), WithHedging(Hedging{Delay: time.Second})); err == nil {
+		t.Fatal("fan out and hedging were combined")
+	}
+}
+
+func TestWithEndpointsRejectsMalformedURLs(t *testing.T) {
+	for _, endpoint := range []string{
+		"api.yubico.com/wsapi/2.0/verify",
+		"ftp://api.yubico.com/wsapi/2.0/verify",
+		"https:///wsapi/2.0/verify",
+		"https://api.yubico.com/wsapi/2.0/verify?id=1",
+		"https://api.yubico.com/wsapi/2.0/verify#fragment",
+		"https://api yubico.com/wsapi/2.0/verify",
+	} {
+		if _, err := New(WithEndpoints(endpoint)); err == nil {
+			t.Errorf("malformed endpoint %q was accepted", endpoint)
+		}
+	}
+}
+
+func TestUnbuildableRequestIsNotRetried(t *testing.T) {
+	authenticator, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = authenticator.exchangeOnce(t.Context(), signedQuery{
+		Query: "otp=" + stubOneTimePassword,
+	}, &Attempt{Endpoint: "https://api yubico.com/wsapi/2.0/verify"})
+	if err == nil {
+		t.Fatal("request to a malformed endpoint was sent")
+	}
+	if isRetryable(err) {
+		t.Errorf("request that cannot be built is retried: %v", err)
+	}
+	if strings.Contains(err.Error(), stubOneTimePassword) {
+		t.Errorf("error message leaks the one time password: %v", err)
+	}
+}
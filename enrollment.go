@@ -0,0 +1,90 @@
+package yubikeyotp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Enrollment registers YubiKeys to user accounts. The user proves
+// possession of a key by touching it, the one time password is validated,
+// and the public identifier of the key is stored against the account.
+// Create only with [NewEnrollment] constructor.
+type Enrollment struct {
+	verifier    Verifier
+	store       DeviceStore
+	deviceLimit int
+}
+
+// NewEnrollment creates an [Enrollment] that validates one time passwords
+// using the verifier, usually an [Authenticator], and keeps registered
+// devices in the store. Each user may register up to deviceLimit keys.
+func NewEnrollment(verifier Verifier, store DeviceStore, deviceLimit int) (*Enrollment, error) {
+	if verifier == nil {
+		return nil, errors.New("unable to initialize Yubi Key enrollment: verifier is nil")
+	}
+	if store == nil {
+		return nil, errors.New("unable to initialize Yubi Key enrollment: device store is nil")
+	}
+	if deviceLimit < 1 {
+		return nil, errors.New("unable to initialize Yubi Key enrollment: device limit must be greater than zero")
+	}
+	return &Enrollment{
+		verifier:    verifier,
+		store:       store,
+		deviceLimit: deviceLimit,
+	}, nil
+}
+
+// Enroll validates the one time password and registers the YubiKey
+// that produced it to the user under the given label.
+func (e *Enrollment) Enroll(ctx context.Context, userID, label string, r Request) (*Device, error) {
+	if userID == "" {
+		return nil, errors.New("user identifier is empty")
+	}
+	if err := validateDeviceLabel(label); err != nil {
+		return nil, err
+	}
+	if _, err := ParseOTP(r.OneTimePassword); err != nil {
+		return nil, err
+	}
+	// check the limit early to avoid spending the one time password
+	existing, err := e.store.ListDevices(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list registered YubiKeys: %w", err)
+	}
+	if len(existing) >= e.deviceLimit {
+		return nil, ErrRegistryDeviceLimitReached
+	}
+
+	result, err := e.verifier.Verify(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	device := Device{
+		PublicID:   result.PublicID,
+		Label:      label,
+		EnrolledAt: time.Now().UTC(),
+	}
+	if err = e.store.AddDevice(ctx, userID, device, e.deviceLimit); err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// List returns YubiKeys registered to the user.
+func (e *Enrollment) List(ctx context.Context, userID string) ([]Device, error) {
+	return e.store.ListDevices(ctx, userID)
+}
+
+// Label renames a YubiKey registered to the user.
+func (e *Enrollment) Label(ctx context.Context, userID, publicID, label string) error {
+	return e.store.LabelDevice(ctx, userID, publicID, label)
+}
+
+// Revoke removes a YubiKey from the user account. Its one time
+// passwords will no longer pass [Authenticator.AuthenticateUser].
+func (e *Enrollment) Revoke(ctx context.Context, userID, publicID string) error {
+	return e.store.RevokeDevice(ctx, userID, publicID)
+}
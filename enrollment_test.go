@@ -0,0 +1,65 @@
+package yubikeyotp
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEnrollment(t *testing.T) {
+	keys := LocalKeyMap{}
+	for _, publicID := range []string{"vvccccfiluij", "vvccccfiluik", "vvccccfiluil"} {
+		key := testLocalKey(t)
+		key.PublicID = publicID
+		keys[publicID] = key
+	}
+	validator, err := NewLocalValidator(keys, newTestReplayStore(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := NewMemoryRegistry()
+	enrollment, err := NewEnrollment(validator, registry, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	touch := func(publicID string, counter uint16) Request {
+		return Request{OneTimePassword: encryptTestToken(t, keys[publicID], counter, 0)}
+	}
+
+	device, err := enrollment.Enroll(t.Context(), "alice", "office", touch("vvccccfiluij", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.PublicID != "vvccccfiluij" || device.Label != "office" || device.EnrolledAt.IsZero() {
+		t.Errorf("unexpected device: %+v", device)
+	}
+	if _, err = enrollment.Enroll(t.Context(), "bob", "stolen", touch("vvccccfiluij", 2)); !errors.Is(err, ErrRegistryDeviceAlreadyRegistered) {
+		t.Errorf("expected error %v, got %v", ErrRegistryDeviceAlreadyRegistered, err)
+	}
+	if _, err = enrollment.Enroll(t.Context(), "alice", "home", touch("vvccccfiluik", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = enrollment.Enroll(t.Context(), "alice", "spare", touch("vvccccfiluil", 1)); !errors.Is(err, ErrRegistryDeviceLimitReached) {
+		t.Errorf("expected error %v, got %v", ErrRegistryDeviceLimitReached, err)
+	}
+
+	if err = enrollment.Label(t.Context(), "alice", "vvccccfiluik", "travel"); err != nil {
+		t.Fatal(err)
+	}
+	if err = enrollment.Revoke(t.Context(), "alice", "vvccccfiluij"); err != nil {
+		t.Fatal(err)
+	}
+	if err = enrollment.Revoke(t.Context(), "alice", "vvccccfiluij"); !errors.Is(err, ErrRegistryDeviceNotRegistered) {
+		t.Errorf("expected error %v, got %v", ErrRegistryDeviceNotRegistered, err)
+	}
+	devices, err := enrollment.List(t.Context(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 1 || devices[0].PublicID != "vvccccfiluik" || devices[0].Label != "travel" {
+		t.Fatalf("unexpected devices: %+v", devices)
+	}
+	if _, err = enrollment.Enroll(t.Context(), "alice", "spare", touch("vvccccfiluil", 2)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,5 +1,12 @@
 package yubikeyotp
 
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
 type RequestError uint8
 
 const (
@@ -12,6 +19,11 @@ const (
 	ErrRequestForbidden
 	ErrRequestDeadlineExceeded
 	ErrRequestBackendError
+	// ErrRequestReplayedRequest does not match [ErrRequestReplayed] using
+	// [errors.Is]. Servers return it when the same nonce and one time password
+	// reach them twice, which retries on synchronized endpoints cause, so it
+	// does not indicate that someone else used the one time password.
+	ErrRequestReplayedRequest
 )
 
 func (e RequestError) Error() string {
@@ -34,23 +46,200 @@ func (e RequestError) Error() string {
 		return "server could not obtain the requested number of synchronizations before the deadline"
 	case ErrRequestBackendError:
 		return "server could not process the request"
+	case ErrRequestReplayedRequest:
+		return "server has seen the one time password and nonce combination before"
 	default:
 		return "unknown error"
 	}
 }
 
+// Status returns the validation protocol status that corresponds to the error.
+func (e RequestError) Status() string {
+	switch e {
+	case ErrRequestInvalidFormat:
+		return "BAD_OTP"
+	case ErrRequestReplayed:
+		return "REPLAYED_OTP"
+	case ErrRequestBadSignature:
+		return "BAD_SIGNATURE"
+	case ErrRequestMissingParameter:
+		return "MISSING_PARAMETER"
+	case ErrRequestClientDoesNotExist:
+		return "NO_SUCH_CLIENT"
+	case ErrRequestForbidden:
+		return "OPERATION_NOT_ALLOWED"
+	case ErrRequestDeadlineExceeded:
+		return "NOT_ENOUGH_ANSWERS"
+	case ErrRequestReplayedRequest:
+		return "REPLAYED_REQUEST"
+	default:
+		return "BACKEND_ERROR"
+	}
+}
+
 type ResponseError uint8
 
 const (
 	ErrResponseUnknownFailure ResponseError = iota
 	ErrResponseBadSignature
+	ErrResponseNonceMismatch
+	ErrResponseOneTimePasswordMismatch
+	ErrResponseMissingCounters
 )
 
 func (e ResponseError) Error() string {
 	switch e {
 	case ErrResponseBadSignature:
 		return "bad response signature"
+	case ErrResponseNonceMismatch:
+		return "response nonce does not match the request nonce"
+	case ErrResponseOneTimePasswordMismatch:
+		return "response one time password does not match the request one time password"
+	case ErrResponseMissingCounters:
+		return "response lacks session counters required to detect replays"
 	default:
 		return "unknown response error"
 	}
 }
+
+// StatusCodeError reports an unexpected HTTP status code of a validation server response.
+type StatusCodeError int
+
+func (e StatusCodeError) Error() string {
+	return fmt.Sprintf("validation server responded with HTTP status %d %s", int(e), http.StatusText(int(e)))
+}
+
+// UnverifiedStatusError reports a failure status of a response without
+// a valid signature. It does not match [RequestError] values, because
+// anyone on the network path could have forged the status, but it matches
+// [ErrResponseBadSignature] using [errors.Is]. Such responses are retried
+// on other endpoints.
+type UnverifiedStatusError string
+
+func (e UnverifiedStatusError) Error() string {
+	return fmt.Sprintf("validation server responded with status %q without a valid signature", string(e))
+}
+
+// Is reports [UnverifiedStatusError] as [ErrResponseBadSignature].
+func (e UnverifiedStatusError) Is(target error) bool {
+	return target == ErrResponseBadSignature
+}
+
+// Attempt describes a single request to a validation endpoint.
+type Attempt struct {
+	Endpoint string
+	// Number counts attempts of a validation starting with one.
+	Number   int
+	Duration time.Duration
+	// HTTPStatus is zero if the endpoint did not respond.
+	HTTPStatus int
+	// Status is the protocol status of the response, such as "OK" or "REPLAYED_OTP".
+	Status string
+	// Err is the cause of failure or nil if the attempt succeeded.
+	Err error
+}
+
+// AttemptsError collects every failed attempt of a validation that did not
+// produce a decisive answer. [errors.Is] and [errors.As] match the cause
+// of any attempt.
+type AttemptsError struct {
+	Attempts []Attempt
+}
+
+func (e *AttemptsError) Error() string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "all %d attempts failed", len(e.Attempts))
+	for i, attempt := range e.Attempts {
+		if i == 0 {
+			b.WriteString(": ")
+		} else {
+			b.WriteString("; ")
+		}
+		fmt.Fprintf(&b, "attempt %d to %s after %s: %v", attempt.Number, attempt.Endpoint, attempt.Duration.Round(time.Millisecond), attempt.Err)
+	}
+	return b.String()
+}
+
+func (e *AttemptsError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Attempts))
+	for _, attempt := range e.Attempts {
+		if attempt.Err != nil {
+			errs = append(errs, attempt.Err)
+		}
+	}
+	return errs
+}
+
+// FormatError describes a malformed one time password
+// detected before contacting validation servers.
+type FormatError uint8
+
+const (
+	ErrFormatUnknownFailure FormatError = iota
+	ErrFormatTooShort
+	ErrFormatTooLong
+	ErrFormatOddLength
+	ErrFormatInvalidCharacter
+)
+
+func (e FormatError) Error() string {
+	switch e {
+	case ErrFormatTooShort:
+		return "one time password is shorter than 32 characters"
+	case ErrFormatTooLong:
+		return "one time password is longer than 64 characters"
+	case ErrFormatOddLength:
+		return "modhex string has an odd length"
+	case ErrFormatInvalidCharacter:
+		return "invalid modhex character"
+	default:
+		return "unknown format error"
+	}
+}
+
+// Is reports every [FormatError] as [ErrRequestInvalidFormat],
+// which the validation servers return for malformed one time passwords.
+func (e FormatError) Is(target error) bool {
+	return target == ErrRequestInvalidFormat
+}
+
+// RegistryError describes a failure to bind a YubiKey to a user account.
+type RegistryError uint8
+
+const (
+	ErrRegistryUnknownFailure RegistryError = iota
+	ErrRegistryDeviceNotRegistered
+	ErrRegistryDeviceAlreadyRegistered
+	ErrRegistryDeviceLimitReached
+)
+
+func (e RegistryError) Error() string {
+	switch e {
+	case ErrRegistryDeviceNotRegistered:
+		return "YubiKey is not registered to the user"
+	case ErrRegistryDeviceAlreadyRegistered:
+		return "YubiKey is already registered"
+	case ErrRegistryDeviceLimitReached:
+		return "user cannot register any more YubiKeys"
+	default:
+		return "unknown registry error"
+	}
+}
+
+// LocalKeyError describes a failure to find the secrets of a YubiKey
+// for offline validation.
+type LocalKeyError uint8
+
+const (
+	ErrLocalKeyUnknownFailure LocalKeyError = iota
+	ErrLocalKeyNotFound
+)
+
+func (e LocalKeyError) Error() string {
+	switch e {
+	case ErrLocalKeyNotFound:
+		return "YubiKey secrets are not known"
+	default:
+		return "unknown local key error"
+	}
+}
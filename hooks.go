@@ -0,0 +1,56 @@
+package yubikeyotp
+
+import (
+	"context"
+	"errors"
+)
+
+// Hooks are called synchronously on validation outcomes, for example,
+// to feed security events to a SIEM or to lock an account on a replay attempt.
+// Any hook may be nil. Hooks receive the [Request] with the client secret
+// redacted. In fan out and hedging modes, OnEndpointFailure may be called
+// concurrently.
+type Hooks struct {
+	// OnSuccess is called after a one time password was validated.
+	OnSuccess func(context.Context, Request, *Result)
+	// OnFailure is called after every failed validation, including
+	// the ones reported to OnReplay and OnBadSignature beforehand.
+	OnFailure func(context.Context, Request, error)
+	// OnReplay is called when the one time password was already used,
+	// as reported by a validation server with a signed REPLAYED_OTP status
+	// or by the [ReplayStore]. REPLAYED_REQUEST answers to retried requests
+	// do not call it.
+	OnReplay func(context.Context, Request, error)
+	// OnBadSignature is called when a response signature does not match,
+	// which may indicate tampering, or a validation server rejects
+	// the request signature.
+	OnBadSignature func(context.Context, Request, error)
+	// OnEndpointFailure is called for every attempt that failed because
+	// an endpoint is unhealthy, even if another endpoint answered later.
+	OnEndpointFailure func(context.Context, Request, Attempt)
+}
+
+// redacted returns a copy of the request without the client secret.
+func (r Request) redacted() Request {
+	r.ClientSecret = ""
+	return r
+}
+
+// callOutcomeHooks reports the final outcome of a validation.
+func (a *Authenticator) callOutcomeHooks(ctx context.Context, r Request, result *Result, err error) {
+	if err == nil {
+		if a.hooks.OnSuccess != nil {
+			a.hooks.OnSuccess(ctx, r, result)
+		}
+		return
+	}
+	if a.hooks.OnReplay != nil && errors.Is(err, ErrRequestReplayed) {
+		a.hooks.OnReplay(ctx, r, err)
+	}
+	if a.hooks.OnBadSignature != nil && (errors.Is(err, ErrResponseBadSignature) || errors.Is(err, ErrRequestBadSignature)) {
+		a.hooks.OnBadSignature(ctx, r, err)
+	}
+	if a.hooks.OnFailure != nil {
+		a.hooks.OnFailure(ctx, r, err)
+	}
+}
@@ -0,0 +1,123 @@
+package yubikeyotp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHooks(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(failing.Close)
+
+	called := []string{}
+	record := func(name string, r Request) {
+		if r.ClientSecret != "" {
+			t.Errorf("hook %s received the client secret", name)
+		}
+		if r.OneTimePassword != stubOneTimePassword {
+			t.Errorf("hook %s received unexpected one time password %q", name, r.OneTimePassword)
+		}
+		called = append(called, name)
+	}
+	replayed := atomic.Bool{}
+	authenticator, err := New(
+		WithEndpoints(failing.URL, startStubServer(t, func(query url.Values) map[string]string {
+			if replayed.Load() {
+				return withStatus("REPLAYED_OTP")(query)
+			}
+			return echoFields(query)
+		})),
+		WithRetryPolicy(RetryWithBackOff{
+			AttemptLimit:           3,
+			AttemptDelay:           time.Millisecond * 31,
+			AttemptDelayLimit:      time.Millisecond * 31,
+			AttemptDelayMultiplier: 2,
+		}),
+		WithHooks(Hooks{
+			OnSuccess: func(_ context.Context, r Request, result *Result) {
+				if result == nil {
+					t.Error("successful validation hook received no result")
+				}
+				record("success", r)
+			},
+			OnFailure: func(_ context.Context, r Request, err error) {
+				record("failure", r)
+			},
+			OnReplay: func(_ context.Context, r Request, err error) {
+				if !errors.Is(err, ErrRequestReplayed) {
+					t.Errorf("replay hook received unexpected error: %v", err)
+				}
+				record("replay", r)
+			},
+			OnBadSignature: func(_ context.Context, r Request, err error) {
+				record("bad signature", r)
+			},
+			OnEndpointFailure: func(_ context.Context, r Request, attempt Attempt) {
+				if attempt.Endpoint != failing.URL || attempt.HTTPStatus != http.StatusServiceUnavailable {
+					t.Errorf("unexpected endpoint failure: %+v", attempt)
+				}
+				record("endpoint failure", r)
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = authenticator.Authenticate(t.Context(), stubRequest()); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(called, []string{"endpoint failure", "success"}) {
+		t.Errorf("unexpected hooks were called: %v", called)
+	}
+
+	called = nil
+	replayed.Store(true)
+	if err = authenticator.Authenticate(t.Context(), stubRequest()); !errors.Is(err, ErrRequestReplayed) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the failing endpoint is avoided while a healthy one is available
+	if !slices.Equal(called, []string{"replay", "failure"}) {
+		t.Errorf("unexpected hooks were called: %v", called)
+	}
+}
+
+func TestReplayedRequestsDoNotCallReplayHook(t *testing.T) {
+	authenticator, err := New(
+		WithEndpoints(
+			startStubServer(t, withStatus("NOT_ENOUGH_ANSWERS")),
+			startStubServer(t, withStatus("REPLAYED_REQUEST")),
+			startStubServer(t, withStatus("REPLAYED_REQUEST")),
+		),
+		WithRetryPolicy(RetryWithBackOff{
+			AttemptLimit:           3,
+			AttemptDelay:           time.Millisecond * 31,
+			AttemptDelayLimit:      time.Millisecond * 31,
+			AttemptDelayMultiplier: 2,
+		}),
+		WithHooks(Hooks{
+			OnReplay: func(_ context.Context, _ Request, err error) {
+				t.Errorf("replay hook was called for retried requests: %v", err)
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = authenticator.Authenticate(t.Context(), stubRequest())
+	if !errors.Is(err, ErrRequestReplayedRequest) {
+		t.Fatalf("expected error %v, got %v", ErrRequestReplayedRequest, err)
+	}
+	if errors.Is(err, ErrRequestReplayed) {
+		t.Fatalf("replayed requests were reported as a replayed one time password: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package yubikeyotp
+
+import (
+	"context"
+	"crypto/aes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// LocalKey holds the secrets programmed into a YubiKey OTP slot,
+// which allow validating its one time passwords without network access.
+type LocalKey struct {
+	// PublicID is the modhex identifier that prefixes every one time password of the key.
+	PublicID string
+	// PrivateID is the secret identifier embedded into every encrypted token.
+	PrivateID [6]byte
+	// AESKey is the AES-128 secret that encrypts the tokens.
+	AESKey [16]byte
+}
+
+// LocalKeyStore provides [LocalKey] secrets by YubiKey public identifier.
+// Return [ErrLocalKeyNotFound] for unknown YubiKeys. Other errors, such as
+// database outages, are passed through as backend failures.
+type LocalKeyStore interface {
+	LookupLocalKey(ctx context.Context, publicID string) (LocalKey, error)
+}
+
+// LocalKeyMap satisfies the [LocalKeyStore] interface
+// using keys indexed by their public identifiers.
+type LocalKeyMap map[string]LocalKey
+
+func (m LocalKeyMap) LookupLocalKey(_ context.Context, publicID string) (LocalKey, error) {
+	key, ok := m[publicID]
+	if !ok {
+		return LocalKey{}, fmt.Errorf("YubiKey %q: %w", publicID, ErrLocalKeyNotFound)
+	}
+	return key, nil
+}
+
+// LocalValidator verifies one-time passwords offline by decrypting
+// them with locally held AES keys. Session counters of each key
+// are tracked by a [ReplayStore] and must always move forward.
+// Create only with [NewLocalValidator] constructor.
+type LocalValidator struct {
+	keys    LocalKeyStore
+	replays ReplayStore
+}
+
+// NewLocalValidator creates a [LocalValidator] that takes secrets from the key store
+// and remembers session counters in the replay store. The replay store must never
+// forget counters, because the validator is the only guard against replays.
+// A [MemoryReplayStore] is refused for that reason; use a [FileReplayStore].
+func NewLocalValidator(keys LocalKeyStore, replays ReplayStore) (*LocalValidator, error) {
+	if keys == nil {
+		return nil, errors.New("unable to initialize local Yubi Key validator: key store is nil")
+	}
+	if replays == nil {
+		return nil, errors.New("unable to initialize local Yubi Key validator: replay store is nil")
+	}
+	if _, ok := replays.(*MemoryReplayStore); ok {
+		return nil, errors.New("unable to initialize local Yubi Key validator: memory replay store forgets counters")
+	}
+	return &LocalValidator{
+		keys:    keys,
+		replays: replays,
+	}, nil
+}
+
+// Authenticate verifies a one-time password using locally held YubiKey secrets.
+func (v *LocalValidator) Authenticate(ctx context.Context, r Request) error {
+	_, err := v.Verify(ctx, r)
+	return err
+}
+
+// Verify decrypts a one-time password using locally held YubiKey secrets
+// and returns the verified details of the validation. Client credentials
+// of the [Request] are not used.
+func (v *LocalValidator) Verify(ctx context.Context, r Request) (*Result, error) {
+	otp, err := ParseOTP(r.OneTimePassword)
+	if err != nil {
+		return nil, err
+	}
+	publicID := otp.PublicID
+	key, err := v.keys.LookupLocalKey(ctx, publicID)
+	if errors.Is(err, ErrLocalKeyNotFound) {
+		return nil, fmt.Errorf("%w: %w", ErrRequestInvalidFormat, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to look up YubiKey %q: %w", publicID, err)
+	}
+	t, err := decryptToken(key, otp.Token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRequestInvalidFormat, err)
+	}
+
+	counter := ReplayCounter{Session: uint(t.SessionCounter), Use: uint(t.SessionUse)}
+	if err = v.replays.Advance(ctx, publicID, counter); err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		PublicID:            publicID,
+		SessionCounter:      counter.Session,
+		SessionUse:          counter.Use,
+		ActivationTimestamp: uint(t.Timestamp),
+		SyncPercent:         100,
+		Time:                time.Now().UTC(),
+	}, nil
+}
+
+// token is the decrypted content of a YubiKey one time password.
+type token struct {
+	PrivateID      [6]byte
+	SessionCounter uint16
+	Timestamp      uint32
+	SessionUse     uint8
+}
+
+func decryptToken(key LocalKey, ciphertext [16]byte) (t token, err error) {
+	block, err := aes.NewCipher(key.AESKey[:])
+	if err != nil {
+		return t, err
+	}
+	plaintext := make([]byte, aes.BlockSize)
+	block.Decrypt(plaintext, ciphertext[:])
+	if crc16(plaintext) != crc16Residue {
+		return t, errors.New("token checksum does not match")
+	}
+
+	copy(t.PrivateID[:], plaintext[0:6])
+	if t.PrivateID != key.PrivateID {
+		return t, errors.New("token private identifier does not match")
+	}
+	// the highest bit of the usage counter is reserved
+	t.SessionCounter = binary.LittleEndian.Uint16(plaintext[6:8]) & 0x7fff
+	t.Timestamp = uint32(binary.LittleEndian.Uint16(plaintext[8:10])) | uint32(plaintext[10])<<16
+	t.SessionUse = plaintext[11]
+	return t, nil
+}
+
+// crc16Residue is the ISO13239 checksum of a token that includes its own valid checksum.
+const crc16Residue = 0xf0b8
+
+func crc16(data []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for range 8 {
+			lowest := crc & 1
+			crc >>= 1
+			if lowest != 0 {
+				crc ^= 0x8408
+			}
+		}
+	}
+	return crc
+}
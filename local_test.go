@@ -0,0 +1,149 @@
+package yubikeyotp
+
+import (
+	"context"
+	"crypto/aes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testLocalKey(t *testing.T) LocalKey {
+	t.Helper()
+	key := LocalKey{PublicID: "vvccccfiluij"}
+	if _, err := hex.Decode(key.AESKey[:], []byte("ecde18dbe76fbd0c33330f1c354871db")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hex.Decode(key.PrivateID[:], []byte("8792ebfe26cc")); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// encryptTestToken produces a one time password the way a YubiKey does.
+func encryptTestToken(t *testing.T, key LocalKey, counter uint16, use uint8) string {
+	t.Helper()
+	plaintext := make([]byte, aes.BlockSize)
+	copy(plaintext, key.PrivateID[:])
+	binary.LittleEndian.PutUint16(plaintext[6:8], counter)
+	binary.LittleEndian.PutUint16(plaintext[8:10], 0xa1b2)
+	plaintext[10] = 0x03
+	plaintext[11] = use
+	binary.LittleEndian.PutUint16(plaintext[14:16], ^crc16(plaintext[:14]))
+
+	block, err := aes.NewCipher(key.AESKey[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext := make([]byte, aes.BlockSize)
+	block.Encrypt(ciphertext, plaintext)
+	return key.PublicID + EncodeModhex(ciphertext)
+}
+
+func newTestReplayStore(t *testing.T) ReplayStore {
+	t.Helper()
+	store, err := NewFileReplayStore(filepath.Join(t.TempDir(), "counters.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestLocalValidation(t *testing.T) {
+	key := testLocalKey(t)
+	validator, err := NewLocalValidator(LocalKeyMap{key.PublicID: key}, newTestReplayStore(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := validator.Verify(t.Context(), Request{
+		OneTimePassword: encryptTestToken(t, key, 5, 2),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.PublicID != key.PublicID || result.SessionCounter != 5 || result.SessionUse != 2 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if result.ActivationTimestamp != 0x03a1b2 {
+		t.Errorf("unexpected timestamp: %x", result.ActivationTimestamp)
+	}
+
+	for _, tc := range []struct {
+		Name            string
+		OneTimePassword string
+		Expected        error
+	}{
+		{"same counters", encryptTestToken(t, key, 5, 2), ErrRequestReplayed},
+		{"older session", encryptTestToken(t, key, 4, 9), ErrRequestReplayed},
+		{"garbage", "vvccccfiluij" + "xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx", ErrRequestInvalidFormat},
+		{"too short", "vvccccfiluij", ErrFormatTooShort},
+		{"unknown key", "cccccccccccc" + encryptTestToken(t, key, 6, 0)[12:], ErrRequestInvalidFormat},
+		{"corrupted ciphertext", encryptTestToken(t, key, 6, 0)[:43] + "c", ErrRequestInvalidFormat},
+		{"private ID mismatch", encryptTestToken(t, LocalKey{PublicID: key.PublicID, AESKey: key.AESKey}, 6, 0), ErrRequestInvalidFormat},
+		{"next use in session", encryptTestToken(t, key, 5, 3), nil},
+		{"next session", encryptTestToken(t, key, 6, 0), nil},
+		{"replay of next use", encryptTestToken(t, key, 5, 3), ErrRequestReplayed},
+		{"reserved counter bit", encryptTestToken(t, key, 0x8007, 0), nil},
+	} {
+		_, err := validator.Verify(t.Context(), Request{OneTimePassword: tc.OneTimePassword})
+		if tc.Expected == nil && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.Name, err)
+		} else if tc.Expected != nil && !errors.Is(err, tc.Expected) {
+			t.Errorf("%s: expected error %v, got %v", tc.Name, tc.Expected, err)
+		}
+	}
+}
+
+func TestLocalValidationSurvivesRestart(t *testing.T) {
+	key := testLocalKey(t)
+	path := filepath.Join(t.TempDir(), "counters.json")
+	otp := encryptTestToken(t, key, 5, 2)
+	for i, expected := range []error{nil, ErrRequestReplayed} {
+		replays, err := NewFileReplayStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		validator, err := NewLocalValidator(LocalKeyMap{key.PublicID: key}, replays)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = validator.Verify(t.Context(), Request{OneTimePassword: otp}); !errors.Is(err, expected) {
+			t.Fatalf("validation %d: expected error %v, got %v", i+1, expected, err)
+		}
+	}
+}
+
+type failingLocalKeyStore struct{}
+
+func (failingLocalKeyStore) LookupLocalKey(context.Context, string) (LocalKey, error) {
+	return LocalKey{}, errors.New("database is unreachable")
+}
+
+func TestLocalValidationPassesThroughKeyStoreFailures(t *testing.T) {
+	validator, err := NewLocalValidator(failingLocalKeyStore{}, newTestReplayStore(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = validator.Verify(t.Context(), Request{OneTimePassword: encryptTestToken(t, testLocalKey(t), 1, 0)})
+	if err == nil {
+		t.Fatal("key store failure was ignored")
+	}
+	if errors.Is(err, ErrRequestInvalidFormat) {
+		t.Fatalf("key store failure was reported as invalid format: %v", err)
+	}
+}
+
+func TestLocalValidatorRefusesForgetfulReplayStore(t *testing.T) {
+	memory, err := NewMemoryReplayStore(10, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := testLocalKey(t)
+	if _, err = NewLocalValidator(LocalKeyMap{key.PublicID: key}, memory); err == nil {
+		t.Fatal("memory replay store was accepted")
+	}
+}
@@ -0,0 +1,46 @@
+package yubikeyotp
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// logAttempt records the attempt. One time passwords and secrets are never
+// logged, because the attempt does not carry them.
+func (a *Authenticator) logAttempt(ctx context.Context, record Attempt) {
+	level := slog.LevelDebug
+	attributes := []slog.Attr{
+		slog.String("endpoint", record.Endpoint),
+		slog.Int("attempt", record.Number),
+		slog.Duration("latency", record.Duration),
+		slog.Int("http_status", record.HTTPStatus),
+		slog.String("status", record.Status),
+	}
+	if record.Err != nil {
+		level = slog.LevelWarn
+		attributes = append(attributes, slog.String("error", record.Err.Error()))
+	}
+	a.logger.LogAttrs(ctx, level, "YubiKey validation attempt", attributes...)
+}
+
+// logOutcome records the final outcome of a validation.
+// Only the public identifier of the YubiKey is logged.
+func (a *Authenticator) logOutcome(ctx context.Context, publicID string, started time.Time, result *Result, err error) {
+	attributes := []slog.Attr{
+		slog.String("public_id", publicID),
+		slog.Duration("duration", time.Since(started)),
+	}
+	if err != nil {
+		attributes = append(attributes, slog.String("error", err.Error()))
+		a.logger.LogAttrs(ctx, slog.LevelWarn, "YubiKey validation failed", attributes...)
+		return
+	}
+	attributes = append(attributes,
+		slog.String("endpoint", result.Endpoint),
+		slog.Uint64("session_counter", uint64(result.SessionCounter)),
+		slog.Uint64("session_use", uint64(result.SessionUse)),
+		slog.Uint64("sync_percent", uint64(result.SyncPercent)),
+	)
+	a.logger.LogAttrs(ctx, slog.LevelInfo, "YubiKey validation succeeded", attributes...)
+}
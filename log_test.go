@@ -0,0 +1,76 @@
+package yubikeyotp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoggingRedactsSecrets(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(failing.Close)
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	buffer := &bytes.Buffer{}
+	authenticator, err := New(
+		WithEndpoints(failing.URL, unreachable.URL, startStubServer(t, echoFields)),
+		WithLogger(slog.New(slog.NewJSONHandler(buffer, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+		WithRetryPolicy(RetryWithBackOff{
+			AttemptLimit:           3,
+			AttemptDelay:           time.Millisecond * 31,
+			AttemptDelayLimit:      time.Millisecond * 31,
+			AttemptDelayMultiplier: 2,
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = authenticator.Authenticate(t.Context(), stubRequest()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, secret := range []string{
+		stubOneTimePassword[12:],
+		base64.StdEncoding.EncodeToString(stubClientSecret),
+		"nonce=",
+	} {
+		if strings.Contains(buffer.String(), secret) {
+			t.Fatalf("log contains a secret %q:\n%s", secret, buffer.String())
+		}
+	}
+
+	var records []map[string]any
+	scanner := bufio.NewScanner(buffer)
+	for scanner.Scan() {
+		record := make(map[string]any)
+		if err = json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 4 {
+		t.Fatalf("expected three attempts and an outcome, got %d records", len(records))
+	}
+	if records[0]["http_status"] != float64(http.StatusBadGateway) || records[0]["level"] != "WARN" {
+		t.Errorf("unexpected first attempt record: %v", records[0])
+	}
+	if records[1]["http_status"] != float64(0) || records[1]["error"] == nil {
+		t.Errorf("unexpected second attempt record: %v", records[1])
+	}
+	if records[2]["status"] != "OK" || records[2]["attempt"] != float64(3) {
+		t.Errorf("unexpected third attempt record: %v", records[2])
+	}
+	if records[3]["public_id"] != "vvccccfiluij" || records[3]["level"] != "INFO" {
+		t.Errorf("unexpected outcome record: %v", records[3])
+	}
+}
@@ -0,0 +1,83 @@
+package yubikeyotp
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Metrics collects counters and latency histograms of validations
+// without adding dependencies to this package. See the promyubikeyotp
+// module for a Prometheus implementation.
+//
+// Outcome labels are "OK", the protocol status of a [RequestError],
+// such as "REPLAYED_OTP" or "BACKEND_ERROR", one of "BAD_RESPONSE_SIGNATURE",
+// "NONCE_MISMATCH", "OTP_MISMATCH", "BAD_RESPONSE" for a [ResponseError],
+// "UNVERIFIED_STATUS" for an [UnverifiedStatusError],
+// "HTTP_ERROR" for a [StatusCodeError], "UNAVAILABLE" when every attempt
+// failed, "CANCELED", "TIMEOUT", or "ERROR" for anything else,
+// such as a network failure.
+type Metrics interface {
+	// ObserveVerification records the outcome and duration of a validation.
+	ObserveVerification(outcome string, duration time.Duration)
+	// ObserveAttempt records the outcome and latency of a single request.
+	ObserveAttempt(endpoint, outcome string, duration time.Duration)
+	// CountRetry records a request repeated after a transient failure.
+	CountRetry()
+	// CountEndpointRotation records a request sent to a different endpoint
+	// than the previous request of the same validation.
+	CountEndpointRotation()
+	// CountSignatureFailure records a response with an invalid signature,
+	// which may indicate an attempt to tamper with validation.
+	CountSignatureFailure(endpoint string)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveVerification(string, time.Duration)    {}
+func (noopMetrics) ObserveAttempt(string, string, time.Duration) {}
+func (noopMetrics) CountRetry()                                  {}
+func (noopMetrics) CountEndpointRotation()                       {}
+func (noopMetrics) CountSignatureFailure(string)                 {}
+
+// outcome returns the [Metrics] label of a validation or attempt error.
+func outcome(err error) string {
+	var (
+		requestError    RequestError
+		responseError   ResponseError
+		statusCodeError StatusCodeError
+		attemptsError   *AttemptsError
+		unverified      UnverifiedStatusError
+	)
+	switch {
+	case err == nil:
+		return "OK"
+	case errors.Is(err, context.Canceled):
+		return "CANCELED"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "TIMEOUT"
+	case errors.As(err, &attemptsError):
+		return "UNAVAILABLE"
+	case errors.As(err, &unverified):
+		return "UNVERIFIED_STATUS"
+	case errors.As(err, &requestError):
+		return requestError.Status()
+	case errors.Is(err, ErrRequestInvalidFormat):
+		return ErrRequestInvalidFormat.Status()
+	case errors.As(err, &responseError):
+		switch responseError {
+		case ErrResponseBadSignature:
+			return "BAD_RESPONSE_SIGNATURE"
+		case ErrResponseNonceMismatch:
+			return "NONCE_MISMATCH"
+		case ErrResponseOneTimePasswordMismatch:
+			return "OTP_MISMATCH"
+		default:
+			return "BAD_RESPONSE"
+		}
+	case errors.As(err, &statusCodeError):
+		return "HTTP_ERROR"
+	default:
+		return "ERROR"
+	}
+}
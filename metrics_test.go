@@ -0,0 +1,114 @@
+package yubikeyotp
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOutcome(t *testing.T) {
+	for err, expected := range map[error]string{
+		nil:                       "OK",
+		ErrRequestReplayed:        "REPLAYED_OTP",
+		ErrRequestReplayedRequest: "REPLAYED_REQUEST",
+		ErrFormatTooShort:         "BAD_OTP",
+		fmt.Errorf("wrapped: %w", ErrResponseBadSignature):                 "BAD_RESPONSE_SIGNATURE",
+		ErrResponseNonceMismatch:                                           "NONCE_MISMATCH",
+		StatusCodeError(502):                                               "HTTP_ERROR",
+		&AttemptsError{Attempts: []Attempt{{Err: ErrRequestBackendError}}}: "UNAVAILABLE",
+		fmt.Errorf("%w: %w", context.DeadlineExceeded, &AttemptsError{}):   "TIMEOUT",
+		errors.New("connection refused"):                                   "ERROR",
+	} {
+		if actual := outcome(err); actual != expected {
+			t.Errorf("outcome of %v is %q instead of %q", err, actual, expected)
+		}
+	}
+}
+
+type recordedMetrics struct {
+	mu                sync.Mutex
+	Verifications     []string
+	Attempts          []string
+	Retries           int
+	Rotations         int
+	SignatureFailures []string
+}
+
+func (m *recordedMetrics) ObserveVerification(outcome string, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Verifications = append(m.Verifications, outcome)
+}
+
+func (m *recordedMetrics) ObserveAttempt(_, outcome string, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Attempts = append(m.Attempts, outcome)
+}
+
+func (m *recordedMetrics) CountRetry() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Retries++
+}
+
+func (m *recordedMetrics) CountEndpointRotation() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Rotations++
+}
+
+func (m *recordedMetrics) CountSignatureFailure(endpoint string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.SignatureFailures = append(m.SignatureFailures, endpoint)
+}
+
+func TestMetricsCollection(t *testing.T) {
+	failing := startStubServer(t, withStatus("BACKEND_ERROR"))
+	healthy := startStubServer(t, echoFields)
+	metrics := &recordedMetrics{}
+	authenticator, err := New(
+		WithEndpoints(failing, healthy),
+		WithMetrics(metrics),
+		WithRetryPolicy(RetryWithBackOff{
+			AttemptLimit:           3,
+			AttemptDelay:           time.Millisecond * 31,
+			AttemptDelayLimit:      time.Millisecond * 31,
+			AttemptDelayMultiplier: 2,
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = authenticator.Authenticate(t.Context(), stubRequest()); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(metrics.Attempts, []string{"BACKEND_ERROR", "OK"}) {
+		t.Errorf("unexpected attempts: %v", metrics.Attempts)
+	}
+	if metrics.Retries != 1 || metrics.Rotations != 1 {
+		t.Errorf("expected one retry and one rotation, got %d and %d", metrics.Retries, metrics.Rotations)
+	}
+
+	authenticator, err = New(WithEndpoints(healthy), WithMetrics(metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := stubRequest()
+	forged.ClientSecret = base64.StdEncoding.EncodeToString([]byte("another secret"))
+	if err = authenticator.Authenticate(t.Context(), forged); !errors.Is(err, ErrResponseBadSignature) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(metrics.SignatureFailures) != 1 {
+		t.Errorf("expected one signature failure, got %v", metrics.SignatureFailures)
+	}
+	if !slices.Equal(metrics.Verifications, []string{"OK", "BAD_RESPONSE_SIGNATURE"}) {
+		t.Errorf("unexpected verifications: %v", metrics.Verifications)
+	}
+}
@@ -0,0 +1,104 @@
+package yubikeyotp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// modhexAlphabet replaces hexadecimal digits 0-f with characters
+// that occupy the same positions across keyboard layouts.
+const modhexAlphabet = "cbdefghijklnrtuv"
+
+// EncodeModhex returns the modhex encoding of bytes, which YubiKeys
+// use to type one time passwords regardless of keyboard layout.
+func EncodeModhex(b []byte) string {
+	encoded := make([]byte, len(b)*2)
+	for i, c := range b {
+		encoded[i*2] = modhexAlphabet[c>>4]
+		encoded[i*2+1] = modhexAlphabet[c&0x0f]
+	}
+	return string(encoded)
+}
+
+// DecodeModhex returns the bytes represented by a modhex string.
+func DecodeModhex(s string) ([]byte, error) {
+	if len(s)%2 != 0 {
+		return nil, fmt.Errorf("%w: length %d", ErrFormatOddLength, len(s))
+	}
+	decoded := make([]byte, len(s)/2)
+	for i := 0; i < len(s); i += 2 {
+		high := strings.IndexByte(modhexAlphabet, s[i])
+		if high == -1 {
+			return nil, fmt.Errorf("%w %q at position %d", ErrFormatInvalidCharacter, s[i], i)
+		}
+		low := strings.IndexByte(modhexAlphabet, s[i+1])
+		if low == -1 {
+			return nil, fmt.Errorf("%w %q at position %d", ErrFormatInvalidCharacter, s[i+1], i+1)
+		}
+		decoded[i/2] = byte(high<<4 | low)
+	}
+	return decoded, nil
+}
+
+// OTP is a YubiKey one time password split into its parts.
+type OTP struct {
+	// PublicID is the modhex identifier of the YubiKey. It is
+	// usually 12 characters long and may be empty.
+	PublicID string
+	// Token is the AES-128 encrypted one time password.
+	Token [16]byte
+}
+
+// String returns the one time password as typed by the YubiKey.
+func (o OTP) String() string {
+	return o.PublicID + EncodeModhex(o.Token[:])
+}
+
+// dvorakAlphabet holds the characters that a YubiKey types instead of
+// [modhexAlphabet] when the computer uses the Dvorak keyboard layout.
+const dvorakAlphabet = "jxe.uidchtnbpygk"
+
+// normalizeOTP lowercases the one time password, which is typed in upper
+// case while Caps Lock is on, and translates it from the Dvorak keyboard
+// layout, unless it is already valid modhex.
+func normalizeOTP(s string) string {
+	lower := []byte(s)
+	for i, c := range lower {
+		if 'A' <= c && c <= 'Z' {
+			lower[i] = c + 'a' - 'A'
+		}
+	}
+	s = string(lower)
+	if strings.Trim(s, modhexAlphabet) == "" || strings.Trim(s, dvorakAlphabet) != "" {
+		return s
+	}
+	for i, c := range lower {
+		lower[i] = modhexAlphabet[strings.IndexByte(dvorakAlphabet, c)]
+	}
+	return string(lower)
+}
+
+// ParseOTP checks the structure of a one time password typed by a YubiKey
+// without contacting validation servers. Upper case one time passwords
+// and those typed with the Dvorak keyboard layout are accepted;
+// [OTP.String] returns them in modhex. Returned errors match
+// [ErrRequestInvalidFormat] using [errors.Is].
+func ParseOTP(s string) (o OTP, err error) {
+	s = normalizeOTP(s)
+	if len(s) < 32 {
+		return o, fmt.Errorf("%w: %d characters", ErrFormatTooShort, len(s))
+	}
+	if len(s) > 64 {
+		return o, fmt.Errorf("%w: %d characters", ErrFormatTooLong, len(s))
+	}
+	o.PublicID = s[:len(s)-32]
+	if _, err = DecodeModhex(o.PublicID); err != nil {
+		return o, fmt.Errorf("invalid public identifier: %w", err)
+	}
+	token, err := DecodeModhex(s[len(o.PublicID):])
+	if err != nil {
+		return o, fmt.Errorf("invalid token: %w", err)
+	}
+	copy(o.Token[:], token)
+	return o, nil
+}
@@ -0,0 +1,164 @@
+package yubikeyotp
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestModhexRoundTrip(t *testing.T) {
+	original := []byte{0x00, 0x01, 0x7f, 0x80, 0xfe, 0xff}
+	encoded := EncodeModhex(original)
+	if encoded != "cccbivjcvuvv" {
+		t.Fatalf("unexpected encoding: %q", encoded)
+	}
+	decoded, err := DecodeModhex(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, decoded) {
+		t.Fatalf("round trip mismatch: %x", decoded)
+	}
+}
+
+func TestParseOTP(t *testing.T) {
+	otp, err := ParseOTP(stubOneTimePassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otp.PublicID != "vvccccfiluij" {
+		t.Errorf("unexpected public ID: %q", otp.PublicID)
+	}
+	if otp.String() != stubOneTimePassword {
+		t.Errorf("unexpected string: %q", otp.String())
+	}
+
+	for _, tc := range []struct {
+		Name     string
+		OTP      string
+		Expected error
+	}{
+		{"empty", "", ErrFormatTooShort},
+		{"too short", "vvccccfiluij", ErrFormatTooShort},
+		{"too long", stubOneTimePassword + stubOneTimePassword, ErrFormatTooLong},
+		{"odd public ID", "v" + stubOneTimePassword[12:], ErrFormatOddLength},
+		{"hexadecimal", "0123456789abcdef0123456789abcdef", ErrFormatInvalidCharacter},
+		{"mixed layouts", "vvccccfiluij" + "jxe.uidchtnbpygkjxe.uidchtnbpygk", ErrFormatInvalidCharacter},
+	} {
+		_, err := ParseOTP(tc.OTP)
+		if !errors.Is(err, tc.Expected) {
+			t.Errorf("%s: expected error %v, got %v", tc.Name, tc.Expected, err)
+		}
+		if !errors.Is(err, ErrRequestInvalidFormat) {
+			t.Errorf("%s: error %v does not match %v", tc.Name, err, ErrRequestInvalidFormat)
+		}
+	}
+}
+
+func TestParseOTPNormalizesKeyboardInput(t *testing.T) {
+	for name, typed := range map[string]string{
+		"upper case":        strings.ToUpper(stubOneTimePassword),
+		"dvorak":            "kkjjjjucngchtkthidkyhjhjjnuxkhdjnpptx.y.xidk",
+		"dvorak upper case": "KKJJJJUCNGCHTKTHIDKYHJHJJNUXKHDJNPPTX.Y.XIDK",
+	} {
+		otp, err := ParseOTP(typed)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if otp.String() != stubOneTimePassword {
+			t.Errorf("%s: normalized to %q", name, otp.String())
+		}
+	}
+}
+
+func TestAuthenticationNormalizesOneTimePassword(t *testing.T) {
+	authenticator := newStubServer(t, func(query url.Values) map[string]string {
+		fields := echoFields(query)
+		if query.Get("otp") != stubOneTimePassword {
+			fields["status"] = "BAD_OTP"
+		}
+		return fields
+	})
+	request := stubRequest()
+	request.OneTimePassword = strings.ToUpper(stubOneTimePassword)
+	if err := authenticator.Authenticate(t.Context(), request); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuthenticationFailsFastOnGarbage(t *testing.T) {
+	authenticator, err := New(WithEndpoints("http://127.0.0.1:1/wsapi/2.0/verify"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := stubRequest()
+	request.OneTimePassword = "definitely not a one time password"
+	if err = authenticator.Authenticate(t.Context(), request); !errors.Is(err, ErrRequestInvalidFormat) {
+		t.Fatalf("expected error %v, got %v", ErrRequestInvalidFormat, err)
+	}
+}
+
+func FuzzModhex(f *testing.F) {
+	f.Add([]byte{0x00, 0x01, 0x7f, 0x80, 0xfe, 0xff})
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		encoded := EncodeModhex(b)
+		if len(encoded) != len(b)*2 || strings.Trim(encoded, modhexAlphabet) != "" {
+			t.Fatalf("invalid encoding %q of %x", encoded, b)
+		}
+		decoded, err := DecodeModhex(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, decoded) {
+			t.Fatalf("round trip mismatch: %x became %x", b, decoded)
+		}
+	})
+}
+
+func FuzzDecodeModhex(f *testing.F) {
+	f.Add("cccbivjcvuvv")
+	f.Add("ccc")
+	f.Add("0123456789abcdef")
+
+	f.Fuzz(func(t *testing.T, s string) {
+		decoded, err := DecodeModhex(s)
+		if err != nil {
+			if !errors.Is(err, ErrRequestInvalidFormat) {
+				t.Fatalf("error %v does not match %v", err, ErrRequestInvalidFormat)
+			}
+			return
+		}
+		if encoded := EncodeModhex(decoded); encoded != s {
+			t.Fatalf("%q decoded into %x, which encodes to %q", s, decoded, encoded)
+		}
+	})
+}
+
+func FuzzParseOTP(f *testing.F) {
+	f.Add(stubOneTimePassword)
+	f.Add(stubOneTimePassword[12:])
+	f.Add("VVCCCCFILUIJKVKJGHVTJCJCCLFBVJHCLRRKBETEBGHV")
+	f.Add("kkjjjjucngchtkthidkyhjhjjnuxkhdjnpptx.y.xidk")
+
+	f.Fuzz(func(t *testing.T, s string) {
+		otp, err := ParseOTP(s)
+		if err != nil {
+			return
+		}
+		normalized := normalizeOTP(s)
+		if otp.String() != normalized {
+			t.Fatalf("%q parsed into %q instead of %q", s, otp.String(), normalized)
+		}
+		if !strings.HasPrefix(normalized, otp.PublicID) || len(s)-len(otp.PublicID) != 32 {
+			t.Fatalf("unexpected public identifier %q of %q", otp.PublicID, s)
+		}
+		if reparsed, err := ParseOTP(otp.String()); err != nil || reparsed != otp {
+			t.Fatalf("%q does not parse into itself: %v", otp.String(), err)
+		}
+	})
+}
@@ -19,3 +19,27 @@ func TestDefaultNonceGeneration(t *testing.T) {
 
 	// t.Fatalf("%s", nonce)
 }
+
+func FuzzNonceGenerator(f *testing.F) {
+	f.Add(uint8(1))
+	f.Add(uint8(255))
+
+	f.Fuzz(func(t *testing.T, count uint8) {
+		seen := make(map[Nonce]struct{}, count)
+		for range count {
+			nonce, err := cryptoRandNonceGeneratorWithFourLeadingTimeBytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, b := range nonce {
+				if bytes.IndexByte([]byte(defaultNonceCharacterSet), b) == -1 {
+					t.Fatalf("nonce %q contains invalid byte: %d", nonce, b)
+				}
+			}
+			if _, ok := seen[nonce]; ok {
+				t.Fatalf("nonce %q repeated", nonce)
+			}
+			seen[nonce] = struct{}{}
+		}
+	})
+}
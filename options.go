@@ -3,28 +3,41 @@ package yubikeyotp
 import (
 	"errors"
 	"fmt"
-	"net"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"slices"
 	"strings"
 	"sync"
 	"time"
 )
 
-type RetryWithBackOff struct {
-	AttemptLimit           uint8
-	AttemptDelay           time.Duration
-	AttemptDelayLimit      time.Duration
-	AttemptDelayMultiplier float64
-}
-
 type options struct {
 	NonceGenerator           NonceGenerator
 	SynchronizationFactor    *uint8
 	SynchronizationTimeLimit *uint8
-	Retry                    *RetryWithBackOff
+	Retry                    RetryPolicy
 	Endpoints                []string
 	ClientPool               *sync.Pool
+	FanOut                   bool
+	Hedging                  *Hedging
+	Registry                 Registry
+	ReplayStore              ReplayStore
+	Credentials              CredentialProvider
+	CircuitBreaker           *CircuitBreaker
+	Logger                   *slog.Logger
+	Tracer                   Tracer
+	Metrics                  Metrics
+	Hooks                    *Hooks
+}
+
+// CircuitBreaker ejects an endpoint that fails FailureThreshold times
+// in a row for the CoolDown period. After that, the endpoint
+// receives a single probe request, which closes the circuit
+// on success or ejects the endpoint again on failure.
+type CircuitBreaker struct {
+	FailureThreshold int
+	CoolDown         time.Duration
 }
 
 // Option configures [Authenticator] initialization.
@@ -71,28 +84,44 @@ func defaultEndpoints(o *options) error {
 	return WithEndpoints(DefaultEndpoints...)(o)
 }
 
+func defaultCircuitBreaker(o *options) error {
+	if o.CircuitBreaker != nil {
+		return nil
+	}
+	return WithCircuitBreaker(CircuitBreaker{
+		FailureThreshold: 3,
+		CoolDown:         time.Second * 30,
+	})(o)
+}
+
+func defaultLogger(o *options) error {
+	if o.Logger != nil {
+		return nil
+	}
+	return WithLogger(slog.New(slog.DiscardHandler))(o)
+}
+
 func defaultClientPool(o *options) error {
 	if o.ClientPool != nil {
 		return nil
 	}
-	return WithClientPool(&sync.Pool{
-		New: func() any {
-			return &http.Client{
-				Timeout: time.Second * 5,
-				Transport: &http.Transport{
-					MaxConnsPerHost:     20,
-					MaxIdleConnsPerHost: 5,
-					DialContext: (&net.Dialer{
-						Timeout:   30 * time.Second,
-						KeepAlive: 60 * time.Second,
-					}).DialContext,
-					TLSHandshakeTimeout:   3 * time.Second,
-					ResponseHeaderTimeout: 3 * time.Second,
-					ExpectContinueTimeout: 1 * time.Second,
-				},
-			}
-		},
-	})(o)
+	return WithClientPool(NewClientPool(nil))(o)
+}
+
+func defaultTracer(o *options) error {
+	if o.Tracer != nil {
+		return nil
+	}
+	o.Tracer = noopTracer{}
+	return nil
+}
+
+func defaultMetrics(o *options) error {
+	if o.Metrics != nil {
+		return nil
+	}
+	o.Metrics = noopMetrics{}
+	return nil
 }
 
 // WithNonceGenerator specifies a random value provider that secures cryptographic signature of API requests.
@@ -138,26 +167,217 @@ func WithSynchronizationTimeLimit(d time.Duration) Option {
 
 // WithRetryStrategy specifies a retry strategy for network failures during API requests.
 func WithRetryStrategy(r RetryWithBackOff) Option {
+	return WithRetryPolicy(r)
+}
+
+// WithRetryPolicy specifies a retry policy for network failures during API requests.
+// The package provides [RetryWithBackOff], [RetryWithFullJitter], and
+// [RetryWithDecorrelatedJitter] policies.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(o *options) error {
+		if p == nil {
+			return errors.New("retry policy is nil")
+		}
+		if o.Retry != nil {
+			return errors.New("retry policy is already set")
+		}
+		if v, ok := p.(interface{ validate() error }); ok {
+			if err := v.validate(); err != nil {
+				return err
+			}
+		}
+		o.Retry = p
+		return nil
+	}
+}
+
+// WithFanOut sends each validation request to every endpoint in parallel
+// and accepts the first answer that decides the outcome, as Yubico
+// recommends for validation clients. Once the outcome is decided,
+// the remaining requests are cancelled. A response with status REPLAYED_OTP
+// rejects the one time password. Transient failures of a single endpoint,
+// such as BACKEND_ERROR or a network error, are ignored while other endpoints
+// may still answer. Retry strategy does not apply in this mode.
+func WithFanOut() Option {
+	return func(o *options) error {
+		if o.FanOut {
+			return errors.New("fan out mode was already enabled")
+		}
+		if o.Hedging != nil {
+			return errors.New("fan out mode cannot be combined with hedging")
+		}
+		o.FanOut = true
+		return nil
+	}
+}
+
+// Hedging settings for [WithHedging].
+type Hedging struct {
+	// Delay before the request is sent to the next endpoint
+	// while previous endpoints have not answered.
+	Delay time.Duration
+	// Percentile of observed response latency from 1 to 99 replaces Delay
+	// once enough responses were observed. Zero always uses Delay.
+	Percentile float64
+}
+
+// WithHedging is a middle ground between sending requests to one endpoint
+// at a time and [WithFanOut]. If an endpoint has not answered within the
+// hedging delay, the same signed request is also sent to the next endpoint,
+// and the first decisive answer wins. A transient failure sends the request
+// to the next endpoint immediately. Retry strategy does not apply in this mode.
+func WithHedging(h Hedging) Option {
+	return func(o *options) error {
+		if o.Hedging != nil {
+			return errors.New("hedging is already set")
+		}
+		if o.FanOut {
+			return errors.New("hedging cannot be combined with fan out mode")
+		}
+		if h.Delay < time.Millisecond*10 {
+			return errors.New("hedging delay must be at least 10 milliseconds")
+		}
+		if h.Delay > time.Second*30 {
+			return errors.New("hedging delay must not exceed 30 seconds")
+		}
+		if h.Percentile != 0 && (h.Percentile < 1 || h.Percentile > 99) {
+			return errors.New("hedging percentile must be between 1 and 99")
+		}
+		o.Hedging = &h
+		return nil
+	}
+}
+
+// WithRegistry binds YubiKeys to user accounts for [Authenticator.AuthenticateUser].
+func WithRegistry(r Registry) Option {
 	return func(o *options) error {
-		if r.AttemptLimit == 0 {
-			return errors.New("retry limit must be greater than zero")
+		if r == nil {
+			return errors.New("device registry is nil")
 		}
-		if r.AttemptDelay <= time.Millisecond*30 {
-			return errors.New("retry delay must be greater than 30 milliseconds")
+		if o.Registry != nil {
+			return errors.New("device registry is already set")
+		}
+		o.Registry = r
+		return nil
+	}
+}
+
+// WithReplayStore guards against replayed one time passwords locally.
+// The store records session counters of each successfully validated
+// YubiKey and rejects one time passwords that do not move them forward,
+// even if a validation server accepted them. Use it along with
+// low [WithSynchronizationFactor] values.
+func WithReplayStore(s ReplayStore) Option {
+	return func(o *options) error {
+		if s == nil {
+			return errors.New("replay store is nil")
 		}
-		if r.AttemptDelay > time.Minute {
-			return errors.New("retry delay must be less than one minute")
+		if o.ReplayStore != nil {
+			return errors.New("replay store is already set")
 		}
-		if r.AttemptDelayLimit < r.AttemptDelay {
-			return errors.New("retry delay limit must be greater than retry delay")
+		o.ReplayStore = s
+		return nil
+	}
+}
+
+// WithCredentialProvider looks up API client credentials by [Request] tenant
+// for requests that do not carry a client secret. Looked up credentials
+// are cached for five minutes.
+func WithCredentialProvider(p CredentialProvider) Option {
+	return func(o *options) error {
+		if p == nil {
+			return errors.New("credential provider is nil")
 		}
-		if r.AttemptDelayMultiplier <= 1 {
-			return errors.New("retry multiplier must be greater than one")
+		if o.Credentials != nil {
+			return errors.New("credential provider is already set")
 		}
-		if r.AttemptDelayMultiplier > 10 {
-			return errors.New("retry multiplier must be less than 10")
+		o.Credentials = &credentialCache{
+			provider: p,
+			ttl:      time.Minute * 5,
+			entries:  make(map[string]cachedCredentials),
 		}
-		o.Retry = &r
+		return nil
+	}
+}
+
+// WithCircuitBreaker specifies when failing endpoints are ejected
+// and for how long. Current endpoint health is reported by
+// [Authenticator.EndpointStatus].
+func WithCircuitBreaker(c CircuitBreaker) Option {
+	return func(o *options) error {
+		if o.CircuitBreaker != nil {
+			return errors.New("circuit breaker is already set")
+		}
+		if c.FailureThreshold < 1 {
+			return errors.New("circuit breaker failure threshold must be greater than zero")
+		}
+		if c.CoolDown < time.Second {
+			return errors.New("circuit breaker cool-down period must be at least one second")
+		}
+		if c.CoolDown > time.Hour {
+			return errors.New("circuit breaker cool-down period must not exceed one hour")
+		}
+		o.CircuitBreaker = &c
+		return nil
+	}
+}
+
+// WithLogger emits structured records for every request attempt and
+// for the final outcome of each validation. Attempts are logged with
+// endpoint, attempt number, latency, HTTP status, protocol status, and
+// error. One time passwords and secrets are never logged, only
+// the public identifier of the YubiKey.
+func WithLogger(l *slog.Logger) Option {
+	return func(o *options) error {
+		if l == nil {
+			return errors.New("logger is nil")
+		}
+		if o.Logger != nil {
+			return errors.New("logger is already set")
+		}
+		o.Logger = l
+		return nil
+	}
+}
+
+// WithTracer instruments each validation and each request attempt.
+// Use it with a client pool created by [NewClientPool] with a transport
+// that propagates trace context to the endpoints.
+func WithTracer(t Tracer) Option {
+	return func(o *options) error {
+		if t == nil {
+			return errors.New("tracer is nil")
+		}
+		if o.Tracer != nil {
+			return errors.New("tracer is already set")
+		}
+		o.Tracer = t
+		return nil
+	}
+}
+
+// WithMetrics collects counters and latency histograms of validations
+// and of each request attempt.
+func WithMetrics(m Metrics) Option {
+	return func(o *options) error {
+		if m == nil {
+			return errors.New("metrics collector is nil")
+		}
+		if o.Metrics != nil {
+			return errors.New("metrics collector is already set")
+		}
+		o.Metrics = m
+		return nil
+	}
+}
+
+// WithHooks calls the hooks on validation outcomes.
+func WithHooks(h Hooks) Option {
+	return func(o *options) error {
+		if o.Hooks != nil {
+			return errors.New("hooks are already set")
+		}
+		o.Hooks = &h
 		return nil
 	}
 }
@@ -174,11 +394,24 @@ func WithEndpoints(endpoints ...string) Option {
 			if endpoint != strings.TrimSpace(endpoint) {
 				return errors.New("endpoint cannot contain leading or trailing whitespace")
 			}
+			u, err := url.Parse(endpoint)
+			if err != nil {
+				return fmt.Errorf("invalid endpoint: %w", err)
+			}
+			if u.Scheme != "http" && u.Scheme != "https" {
+				return fmt.Errorf("endpoint %q must use HTTP or HTTPS scheme", endpoint)
+			}
+			if u.Host == "" {
+				return fmt.Errorf("endpoint %q has no host", endpoint)
+			}
+			if u.RawQuery != "" || u.ForceQuery || u.Fragment != "" {
+				return fmt.Errorf("endpoint %q cannot contain a query or a fragment", endpoint)
+			}
 			if slices.Index(o.Endpoints, endpoint) != -1 {
 				return fmt.Errorf("endpoint %q was already added", endpoint)
 			}
+			o.Endpoints = append(o.Endpoints, endpoint)
 		}
-		o.Endpoints = append(o.Endpoints, endpoints...)
 		return nil
 	}
 }
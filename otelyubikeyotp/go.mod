@@ -0,0 +1,26 @@
+module github.com/dkotik/yubikeyotp/otelyubikeyotp
+
+go 1.25.0
+
+require (
+	github.com/dkotik/yubikeyotp v0.0.0-20261016230351-84f81ffc01de
+	go.opentelemetry.io/otel v1.46.0
+	go.opentelemetry.io/otel/sdk v1.46.0
+	go.opentelemetry.io/otel/trace v1.46.0
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/go-logr/logr v1.4.4 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	go.opentelemetry.io/auto/sdk v1.2.1 // indirect
+	go.opentelemetry.io/otel/metric v1.46.0 // indirect
+	golang.org/x/sys v0.47.0 // indirect
+)
+
+// Development in this repository builds against the working tree.
+// Modules that depend on this one ignore the replacement and use
+// the required version above, which must be raised whenever
+// this module starts using newer APIs of the core module.
+replace github.com/dkotik/yubikeyotp => ../
@@ -0,0 +1,184 @@
+/*
+Package otelyubikeyotp traces YubiKey one time password validations
+with OpenTelemetry. It lives in a separate module, so that
+the core package stays free of dependencies.
+
+	tracer := otelyubikeyotp.New()
+	authenticator, err := yubikeyotp.New(
+		yubikeyotp.WithTracer(tracer),
+		yubikeyotp.WithClientPool(yubikeyotp.NewClientPool(tracer.Transport)),
+	)
+
+Each validation gets a span with a child span for every request attempt.
+The public identifier of the YubiKey is recorded as an HMAC-SHA256
+hash keyed with [WithPublicIDKey], because public identifiers are
+short enough to recover from a plain hash.
+*/
+package otelyubikeyotp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+
+	"github.com/dkotik/yubikeyotp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/dkotik/yubikeyotp/otelyubikeyotp"
+
+// Span attribute keys.
+const (
+	AttributePublicIDHash = attribute.Key("yubikeyotp.public_id.hash")
+	AttributeEndpoint     = attribute.Key("yubikeyotp.endpoint")
+	AttributeAttempt      = attribute.Key("yubikeyotp.attempt")
+	AttributeStatus       = attribute.Key("yubikeyotp.status")
+	AttributeSyncPercent  = attribute.Key("yubikeyotp.sync_percent")
+	AttributeHTTPStatus   = attribute.Key("http.response.status_code")
+)
+
+// Tracer satisfies the [yubikeyotp.Tracer] interface.
+// Create only with [New] constructor.
+type Tracer struct {
+	tracer      trace.Tracer
+	propagator  propagation.TextMapPropagator
+	publicIDKey []byte
+}
+
+var _ yubikeyotp.Tracer = (*Tracer)(nil)
+
+// Option configures [Tracer] initialization.
+type Option func(*Tracer)
+
+// WithTracerProvider replaces the global tracer provider.
+func WithTracerProvider(p trace.TracerProvider) Option {
+	return func(t *Tracer) {
+		t.tracer = p.Tracer(instrumentationName)
+	}
+}
+
+// WithPropagator replaces the global text map propagator used by [Tracer.Transport].
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(t *Tracer) {
+		t.propagator = p
+	}
+}
+
+// WithPublicIDKey sets the secret key of public identifier hashes.
+// Share the key between instances to correlate their spans of the same YubiKey.
+func WithPublicIDKey(key []byte) Option {
+	return func(t *Tracer) {
+		t.publicIDKey = key
+	}
+}
+
+// New creates a [Tracer] that uses the global tracer provider
+// and propagator unless options replace them. Without [WithPublicIDKey],
+// the public identifier hash key is random, so that hashes correlate
+// spans of the same process only.
+func New(withOptions ...Option) *Tracer {
+	t := &Tracer{}
+	for _, option := range withOptions {
+		option(t)
+	}
+	if t.tracer == nil {
+		t.tracer = otel.GetTracerProvider().Tracer(instrumentationName)
+	}
+	if t.propagator == nil {
+		t.propagator = otel.GetTextMapPropagator()
+	}
+	if len(t.publicIDKey) == 0 {
+		t.publicIDKey = make([]byte, sha256.Size)
+		_, _ = rand.Read(t.publicIDKey)
+	}
+	return t
+}
+
+func (t *Tracer) StartVerification(ctx context.Context, publicID string) (context.Context, func(*yubikeyotp.Result, error)) {
+	attributes := []attribute.KeyValue{}
+	if publicID != "" {
+		attributes = append(attributes, AttributePublicIDHash.String(t.HashPublicID(publicID)))
+	}
+	ctx, span := t.tracer.Start(ctx, "yubikeyotp.Verify",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attributes...),
+	)
+	return ctx, func(result *yubikeyotp.Result, err error) {
+		defer span.End()
+		if err != nil {
+			recordError(span, err)
+			return
+		}
+		span.SetAttributes(
+			AttributeEndpoint.String(result.Endpoint),
+			AttributeSyncPercent.Int(int(result.SyncPercent)),
+		)
+	}
+}
+
+func (t *Tracer) StartAttempt(ctx context.Context, endpoint string, number int) (context.Context, func(yubikeyotp.Attempt)) {
+	ctx, span := t.tracer.Start(ctx, "yubikeyotp.Attempt",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			AttributeEndpoint.String(endpoint),
+			AttributeAttempt.Int(number),
+		),
+	)
+	return ctx, func(attempt yubikeyotp.Attempt) {
+		defer span.End()
+		if attempt.HTTPStatus != 0 {
+			span.SetAttributes(AttributeHTTPStatus.Int(attempt.HTTPStatus))
+		}
+		if attempt.Status != "" {
+			span.SetAttributes(AttributeStatus.String(attempt.Status))
+		}
+		if attempt.Err != nil {
+			recordError(span, attempt.Err)
+		}
+	}
+}
+
+// Transport wraps the base transport to inject trace context
+// into request headers. Pass it to [yubikeyotp.NewClientPool].
+func (t *Tracer) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		r = r.Clone(r.Context())
+		t.propagator.Inject(r.Context(), propagation.HeaderCarrier(r.Header))
+		return base.RoundTrip(r)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// HashPublicID returns the hexadecimal HMAC-SHA256 hash of a YubiKey
+// public identifier, which correlates spans of the same device
+// without revealing it.
+func (t *Tracer) HashPublicID(publicID string) string {
+	hash := hmac.New(sha256.New, t.publicIDKey)
+	_, _ = hash.Write([]byte(publicID))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func recordError(span trace.Span, err error) {
+	var requestError yubikeyotp.RequestError
+	if errors.As(err, &requestError) {
+		span.SetAttributes(AttributeStatus.String(requestError.Status()))
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
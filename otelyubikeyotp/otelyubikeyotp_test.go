@@ -0,0 +1,154 @@
+package otelyubikeyotp
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dkotik/yubikeyotp"
+	"github.com/dkotik/yubikeyotp/server"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const testOneTimePassword = "vvccccfiluijkvkjghvtjcjcclfbvjhclrrkbetebghv"
+
+var (
+	testClientKey   = []byte("test client key")
+	testPublicIDKey = []byte("test public identifier key")
+)
+
+type verifierFunc func(context.Context, yubikeyotp.Request) (*yubikeyotp.Result, error)
+
+func (f verifierFunc) Verify(ctx context.Context, r yubikeyotp.Request) (*yubikeyotp.Result, error) {
+	return f(ctx, r)
+}
+
+func newTestAuthenticator(t *testing.T, verifier verifierFunc) (*yubikeyotp.Authenticator, *tracetest.InMemoryExporter, *[]string) {
+	t.Helper()
+	handler, err := server.New(server.ClientKeys{1: testClientKey}, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parents := []string{}
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parents = append(parents, r.Header.Get("traceparent"))
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(endpoint.Close)
+
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := New(
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))),
+		WithPropagator(propagation.TraceContext{}),
+		WithPublicIDKey(testPublicIDKey),
+	)
+	authenticator, err := yubikeyotp.New(
+		yubikeyotp.WithEndpoints(endpoint.URL),
+		yubikeyotp.WithTracer(tracer),
+		yubikeyotp.WithClientPool(yubikeyotp.NewClientPool(tracer.Transport)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authenticator, exporter, &parents
+}
+
+func testRequest() yubikeyotp.Request {
+	return yubikeyotp.Request{
+		OneTimePassword: testOneTimePassword,
+		ClientID:        1,
+		ClientSecret:    base64.StdEncoding.EncodeToString(testClientKey),
+	}
+}
+
+func spanAttribute(span tracetest.SpanStub, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestTracerRecordsSpans(t *testing.T) {
+	authenticator, exporter, parents := newTestAuthenticator(t, func(context.Context, yubikeyotp.Request) (*yubikeyotp.Result, error) {
+		return &yubikeyotp.Result{}, nil
+	})
+	if _, err := authenticator.Verify(t.Context(), testRequest()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	attempt, verification := spans[0], spans[1]
+	if attempt.Name != "yubikeyotp.Attempt" || verification.Name != "yubikeyotp.Verify" {
+		t.Fatalf("unexpected spans: %q, %q", attempt.Name, verification.Name)
+	}
+	if attempt.Parent.SpanID() != verification.SpanContext.SpanID() {
+		t.Error("attempt span is not a child of the verification span")
+	}
+
+	publicID, _ := spanAttribute(verification, AttributePublicIDHash)
+	if publicID.AsString() != New(WithPublicIDKey(testPublicIDKey)).HashPublicID(testOneTimePassword[:12]) {
+		t.Errorf("unexpected public identifier hash: %q", publicID.AsString())
+	}
+	if publicID.AsString() == New().HashPublicID(testOneTimePassword[:12]) {
+		t.Error("public identifier hash does not depend on the key")
+	}
+	if sync, _ := spanAttribute(verification, AttributeSyncPercent); sync.AsInt64() != 100 {
+		t.Errorf("unexpected sync percent: %d", sync.AsInt64())
+	}
+	if status, _ := spanAttribute(attempt, AttributeStatus); status.AsString() != "OK" {
+		t.Errorf("unexpected status: %q", status.AsString())
+	}
+	if number, _ := spanAttribute(attempt, AttributeAttempt); number.AsInt64() != 1 {
+		t.Errorf("unexpected attempt number: %d", number.AsInt64())
+	}
+	for _, span := range spans {
+		for _, kv := range span.Attributes {
+			if kv.Value.AsString() == testOneTimePassword[:12] {
+				t.Errorf("span %q reveals the public identifier in %q", span.Name, kv.Key)
+			}
+		}
+	}
+
+	if len(*parents) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(*parents))
+	}
+	propagated := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(
+		t.Context(),
+		propagation.HeaderCarrier(http.Header{"Traceparent": {(*parents)[0]}}),
+	))
+	if propagated.SpanID() != attempt.SpanContext.SpanID() {
+		t.Fatalf("attempt span context was not propagated: %q", (*parents)[0])
+	}
+}
+
+func TestTracerRecordsErrors(t *testing.T) {
+	authenticator, exporter, _ := newTestAuthenticator(t, func(context.Context, yubikeyotp.Request) (*yubikeyotp.Result, error) {
+		return nil, yubikeyotp.ErrRequestReplayed
+	})
+	_, err := authenticator.Verify(t.Context(), testRequest())
+	if !errors.Is(err, yubikeyotp.ErrRequestReplayed) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, span := range exporter.GetSpans() {
+		if span.Status.Code != codes.Error {
+			t.Errorf("span %q status is not an error", span.Name)
+		}
+		if status, _ := spanAttribute(span, AttributeStatus); status.AsString() != "REPLAYED_OTP" {
+			t.Errorf("span %q has unexpected status: %q", span.Name, status.AsString())
+		}
+	}
+}
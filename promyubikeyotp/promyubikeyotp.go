@@ -0,0 +1,114 @@
+/*
+Package promyubikeyotp exports YubiKey one time password validation
+metrics to Prometheus. It lives in a separate module, so that
+the core package stays free of dependencies.
+
+	metrics, err := promyubikeyotp.New(prometheus.DefaultRegisterer)
+	if err != nil {
+		return err
+	}
+	authenticator, err := yubikeyotp.New(yubikeyotp.WithMetrics(metrics))
+
+Alert on the growth of yubikeyotp_signature_failures_total: responses
+with invalid signatures may indicate a man-in-the-middle attack.
+*/
+package promyubikeyotp
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dkotik/yubikeyotp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "yubikeyotp"
+
+// Metrics satisfies the [yubikeyotp.Metrics] interface.
+// Create only with [New] constructor.
+type Metrics struct {
+	verifications        *prometheus.CounterVec
+	verificationDuration prometheus.Histogram
+	attemptDuration      *prometheus.HistogramVec
+	retries              prometheus.Counter
+	rotations            prometheus.Counter
+	signatureFailures    *prometheus.CounterVec
+}
+
+var _ yubikeyotp.Metrics = (*Metrics)(nil)
+
+// New creates [Metrics] and registers them with the registerer.
+func New(r prometheus.Registerer) (*Metrics, error) {
+	if r == nil {
+		return nil, errors.New("unable to initialize YubiKey metrics: registerer is nil")
+	}
+	m := &Metrics{
+		verifications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "verifications_total",
+			Help:      "One time password validations by outcome.",
+		}, []string{"outcome"}),
+		verificationDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "verification_duration_seconds",
+			Help:      "Duration of one time password validations including retries.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		attemptDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "attempt_duration_seconds",
+			Help:      "Latency of requests to validation endpoints by outcome.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"endpoint", "outcome"}),
+		retries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "retries_total",
+			Help:      "Requests repeated after transient failures.",
+		}),
+		rotations: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "endpoint_rotations_total",
+			Help:      "Requests sent to a different endpoint than the previous request of the same validation.",
+		}),
+		signatureFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "signature_failures_total",
+			Help:      "Responses with invalid signatures by endpoint.",
+		}, []string{"endpoint"}),
+	}
+	for _, collector := range []prometheus.Collector{
+		m.verifications,
+		m.verificationDuration,
+		m.attemptDuration,
+		m.retries,
+		m.rotations,
+		m.signatureFailures,
+	} {
+		if err := r.Register(collector); err != nil {
+			return nil, fmt.Errorf("unable to initialize YubiKey metrics: %w", err)
+		}
+	}
+	return m, nil
+}
+
+func (m *Metrics) ObserveVerification(outcome string, duration time.Duration) {
+	m.verifications.WithLabelValues(outcome).Inc()
+	m.verificationDuration.Observe(duration.Seconds())
+}
+
+func (m *Metrics) ObserveAttempt(endpoint, outcome string, duration time.Duration) {
+	m.attemptDuration.WithLabelValues(endpoint, outcome).Observe(duration.Seconds())
+}
+
+func (m *Metrics) CountRetry() {
+	m.retries.Inc()
+}
+
+func (m *Metrics) CountEndpointRotation() {
+	m.rotations.Inc()
+}
+
+func (m *Metrics) CountSignatureFailure(endpoint string) {
+	m.signatureFailures.WithLabelValues(endpoint).Inc()
+}
@@ -0,0 +1,88 @@
+package promyubikeyotp
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dkotik/yubikeyotp"
+	"github.com/dkotik/yubikeyotp/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type verifierFunc func(context.Context, yubikeyotp.Request) (*yubikeyotp.Result, error)
+
+func (f verifierFunc) Verify(ctx context.Context, r yubikeyotp.Request) (*yubikeyotp.Result, error) {
+	return f(ctx, r)
+}
+
+func TestMetrics(t *testing.T) {
+	key := []byte("test client key")
+	handler, err := server.New(server.ClientKeys{1: key}, verifierFunc(func(context.Context, yubikeyotp.Request) (*yubikeyotp.Result, error) {
+		return &yubikeyotp.Result{}, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tamper := false
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, r)
+		body := recorder.Body.String()
+		if tamper {
+			// a man in the middle alters a signed field
+			body = strings.Replace(body, "sl=100", "sl=99", 1)
+		}
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(endpoint.Close)
+
+	registry := prometheus.NewRegistry()
+	metrics, err := New(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator, err := yubikeyotp.New(
+		yubikeyotp.WithEndpoints(endpoint.URL),
+		yubikeyotp.WithMetrics(metrics),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := yubikeyotp.Request{
+		OneTimePassword: "vvccccfiluijkvkjghvtjcjcclfbvjhclrrkbetebghv",
+		ClientID:        1,
+		ClientSecret:    base64.StdEncoding.EncodeToString(key),
+	}
+	if err = authenticator.Authenticate(t.Context(), request); err != nil {
+		t.Fatal(err)
+	}
+	tamper = true
+	if err = authenticator.Authenticate(t.Context(), request); !errors.Is(err, yubikeyotp.ErrResponseBadSignature) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if count := testutil.ToFloat64(metrics.verifications.WithLabelValues("OK")); count != 1 {
+		t.Errorf("expected one successful validation, got %v", count)
+	}
+	if count := testutil.ToFloat64(metrics.verifications.WithLabelValues("BAD_RESPONSE_SIGNATURE")); count != 1 {
+		t.Errorf("expected one validation with a bad signature, got %v", count)
+	}
+	if count := testutil.ToFloat64(metrics.signatureFailures.WithLabelValues(endpoint.URL)); count != 1 {
+		t.Errorf("expected one signature failure, got %v", count)
+	}
+	if count := testutil.CollectAndCount(metrics.attemptDuration); count != 2 {
+		t.Errorf("expected attempt latency by two outcomes, got %d", count)
+	}
+
+	if _, err = New(registry); err == nil {
+		t.Error("registered the same metrics twice")
+	}
+}
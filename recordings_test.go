@@ -0,0 +1,70 @@
+package yubikeyotp
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRecordedResponses checks [parseResponse] and [response.Verify]
+// against golden files recorded by the yubikeyotptest package.
+// Regenerate them with:
+//
+//	$ go test ./yubikeyotptest -run TestRecordings -update
+//
+// The checked in recordings come from the fake validation server
+// signed with the secret below, not from the Yubico API.
+func TestRecordedResponses(t *testing.T) {
+	secret := []byte("recording secret")
+	expected := map[string]error{
+		"ok.json":            nil,
+		"replayed_otp.json":  ErrRequestReplayed,
+		"backend_error.json": ErrRequestBackendError,
+		"bad_signature.json": ErrResponseBadSignature,
+	}
+
+	paths, err := filepath.Glob(filepath.Join("testdata", "recordings", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != len(expected) {
+		t.Fatalf("expected %d recordings, found %d", len(expected), len(paths))
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var exchanges []struct {
+				Body string `json:"body"`
+			}
+			if err = json.Unmarshal(data, &exchanges); err != nil {
+				t.Fatal(err)
+			}
+			for _, exchange := range exchanges {
+				response, err := parseResponse(strings.NewReader(exchange.Body))
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = response.Verify(secret)
+				if want := expected[filepath.Base(path)]; !errors.Is(err, want) {
+					t.Fatalf("expected error %v, got %v", want, err)
+				}
+				if err != nil {
+					continue
+				}
+				result, err := newResult(response, "")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.PublicID != "vvcccccccccc" || result.SessionCounter != 19 || result.SessionUse != 3 || result.ActivationTimestamp != 9127 {
+					t.Fatalf("unexpected result: %+v", result)
+				}
+			}
+		})
+	}
+}
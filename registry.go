@@ -0,0 +1,334 @@
+package yubikeyotp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Registry binds YubiKey public identifiers to user accounts.
+// A valid one time password only proves that some YubiKey was touched;
+// the registry confirms that the key belongs to the user logging in.
+type Registry interface {
+	IsRegistered(ctx context.Context, userID, publicID string) (bool, error)
+}
+
+// DeviceStore manages YubiKeys registered to user accounts for [Enrollment].
+// A YubiKey can be registered to only one user.
+type DeviceStore interface {
+	Registry
+	ListDevices(ctx context.Context, userID string) ([]Device, error)
+	// AddDevice registers the device to the user unless the user
+	// already has limit devices or more. Zero limit means no limit.
+	AddDevice(ctx context.Context, userID string, d Device, limit int) error
+	LabelDevice(ctx context.Context, userID, publicID, label string) error
+	RevokeDevice(ctx context.Context, userID, publicID string) error
+}
+
+var (
+	_ DeviceStore = (*MemoryRegistry)(nil)
+	_ DeviceStore = (*FileRegistry)(nil)
+)
+
+// Device is a YubiKey registered to a user account.
+type Device struct {
+	// PublicID is the modhex identifier of the YubiKey.
+	PublicID string `json:"publicID"`
+	// Label is a human readable name chosen by the user.
+	Label string `json:"label,omitempty"`
+	// EnrolledAt is the time of registration.
+	EnrolledAt time.Time `json:"enrolledAt,omitzero"`
+}
+
+// devices maps user identifiers to their registered YubiKeys.
+type devices map[string][]Device
+
+func (d devices) IsRegistered(userID, publicID string) bool {
+	return slices.ContainsFunc(d[userID], func(device Device) bool {
+		return device.PublicID == publicID
+	})
+}
+
+func (d devices) List(userID string) []Device {
+	return slices.Clone(d[userID])
+}
+
+func (d devices) Add(userID string, device Device, limit int) error {
+	if userID == "" {
+		return errors.New("user identifier is empty")
+	}
+	if device.PublicID == "" || len(device.PublicID) > 32 {
+		return fmt.Errorf("public identifier %q must contain 2 to 32 characters", device.PublicID)
+	}
+	if _, err := DecodeModhex(device.PublicID); err != nil {
+		return fmt.Errorf("invalid public identifier %q: %w", device.PublicID, err)
+	}
+	if err := validateDeviceLabel(device.Label); err != nil {
+		return err
+	}
+	for owner := range d {
+		if d.IsRegistered(owner, device.PublicID) {
+			return ErrRegistryDeviceAlreadyRegistered
+		}
+	}
+	if limit > 0 && len(d[userID]) >= limit {
+		return ErrRegistryDeviceLimitReached
+	}
+	d[userID] = append(d[userID], device)
+	return nil
+}
+
+func (d devices) Label(userID, publicID, label string) error {
+	if err := validateDeviceLabel(label); err != nil {
+		return err
+	}
+	i := slices.IndexFunc(d[userID], func(device Device) bool {
+		return device.PublicID == publicID
+	})
+	if i == -1 {
+		return ErrRegistryDeviceNotRegistered
+	}
+	d[userID][i].Label = label
+	return nil
+}
+
+func (d devices) Revoke(userID, publicID string) error {
+	i := slices.IndexFunc(d[userID], func(device Device) bool {
+		return device.PublicID == publicID
+	})
+	if i == -1 {
+		return ErrRegistryDeviceNotRegistered
+	}
+	d[userID] = slices.Delete(d[userID], i, i+1)
+	if len(d[userID]) == 0 {
+		delete(d, userID)
+	}
+	return nil
+}
+
+func validateDeviceLabel(label string) error {
+	if utf8.RuneCountInString(label) > 64 {
+		return errors.New("device label must not exceed 64 characters")
+	}
+	if !utf8.ValidString(label) {
+		return errors.New("device label is not valid UTF-8")
+	}
+	return nil
+}
+
+// MemoryRegistry satisfies the [Registry] interface
+// by keeping registered devices in memory.
+type MemoryRegistry struct {
+	mu      sync.RWMutex
+	devices devices
+}
+
+// NewMemoryRegistry creates an empty [MemoryRegistry].
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{devices: make(devices)}
+}
+
+func (r *MemoryRegistry) IsRegistered(_ context.Context, userID, publicID string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.devices.IsRegistered(userID, publicID), nil
+}
+
+// Register binds a YubiKey public identifier to the user.
+func (r *MemoryRegistry) Register(ctx context.Context, userID, publicID string) error {
+	return r.AddDevice(ctx, userID, Device{PublicID: publicID, EnrolledAt: time.Now().UTC()}, 0)
+}
+
+func (r *MemoryRegistry) ListDevices(_ context.Context, userID string) ([]Device, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.devices.List(userID), nil
+}
+
+func (r *MemoryRegistry) AddDevice(_ context.Context, userID string, d Device, limit int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.devices.Add(userID, d, limit)
+}
+
+func (r *MemoryRegistry) LabelDevice(_ context.Context, userID, publicID, label string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.devices.Label(userID, publicID, label)
+}
+
+func (r *MemoryRegistry) RevokeDevice(_ context.Context, userID, publicID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.devices.Revoke(userID, publicID)
+}
+
+// FileRegistry satisfies the [Registry] interface by keeping
+// registered devices in a JSON file. Create only with [NewFileRegistry].
+type FileRegistry struct {
+	path string
+
+	mu      sync.RWMutex
+	devices devices
+}
+
+// NewFileRegistry creates a [FileRegistry] that loads devices from
+// the JSON file at path. The file is created on first registration.
+func NewFileRegistry(path string) (*FileRegistry, error) {
+	r := &FileRegistry{path: path, devices: make(devices)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read device registry: %w", err)
+	}
+	if err = json.Unmarshal(data, &r.devices); err != nil {
+		return nil, fmt.Errorf("unable to decode device registry %q: %w", path, err)
+	}
+	return r, nil
+}
+
+func (r *FileRegistry) IsRegistered(_ context.Context, userID, publicID string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.devices.IsRegistered(userID, publicID), nil
+}
+
+// Register binds a YubiKey public identifier to the user and saves the file.
+func (r *FileRegistry) Register(ctx context.Context, userID, publicID string) error {
+	return r.AddDevice(ctx, userID, Device{PublicID: publicID, EnrolledAt: time.Now().UTC()}, 0)
+}
+
+func (r *FileRegistry) ListDevices(_ context.Context, userID string) ([]Device, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.devices.List(userID), nil
+}
+
+func (r *FileRegistry) AddDevice(_ context.Context, userID string, d Device, limit int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.devices.Add(userID, d, limit); err != nil {
+		return err
+	}
+	if err := r.save(); err != nil {
+		_ = r.devices.Revoke(userID, d.PublicID)
+		return err
+	}
+	return nil
+}
+
+func (r *FileRegistry) LabelDevice(_ context.Context, userID, publicID, label string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.devices.Label(userID, publicID, label); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+func (r *FileRegistry) RevokeDevice(_ context.Context, userID, publicID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.devices.Revoke(userID, publicID); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+func (r *FileRegistry) save() error {
+	data, err := json.MarshalIndent(r.devices, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = writeFileAtomically(r.path, data); err != nil {
+		return fmt.Errorf("unable to save device registry: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomically replaces the file through a rename, so that
+// a crash never leaves a partially written file behind. The data
+// and the rename are flushed to disk before returning.
+func writeFileAtomically(path string, data []byte) error {
+	directory := filepath.Dir(path)
+	temporary, err := os.CreateTemp(directory, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+	if _, err = temporary.Write(data); err != nil {
+		_ = temporary.Close()
+		return err
+	}
+	if err = temporary.Sync(); err != nil {
+		_ = temporary.Close()
+		return err
+	}
+	if err = temporary.Close(); err != nil {
+		return err
+	}
+	if err = syncDirectory(directory); err != nil {
+		return err
+	}
+	if err = os.Rename(temporary.Name(), path); err != nil {
+		return err
+	}
+	return syncDirectory(directory)
+}
+
+// syncDirectory flushes directory entries, such as a created or renamed file,
+// to disk. Windows does not support synchronizing directories.
+func syncDirectory(path string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	directory, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	if err = directory.Sync(); err != nil {
+		_ = directory.Close()
+		return err
+	}
+	return directory.Close()
+}
+
+// AuthenticateUser verifies a one-time password using YubiKey API and
+// confirms that the YubiKey that produced it is registered to the user.
+// Keys that are not registered are rejected before contacting validation
+// servers. Requires [WithRegistry] option.
+func (a *Authenticator) AuthenticateUser(ctx context.Context, userID string, r Request) (*Result, error) {
+	if a.registry == nil {
+		return nil, errors.New("device registry is not configured")
+	}
+	otp, err := ParseOTP(r.OneTimePassword)
+	if err != nil {
+		return nil, err
+	}
+	registered, err := a.registry.IsRegistered(ctx, userID, otp.PublicID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to look up YubiKey registration: %w", err)
+	}
+	if !registered {
+		return nil, ErrRegistryDeviceNotRegistered
+	}
+	result, err := a.Verify(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	if result.PublicID != otp.PublicID {
+		// a verified response always echoes the one time password
+		return nil, ErrRegistryDeviceNotRegistered
+	}
+	return result, nil
+}
@@ -0,0 +1,71 @@
+package yubikeyotp
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegistries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.json")
+	fileRegistry, err := NewFileRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, registry := range map[string]interface {
+		Registry
+		Register(context.Context, string, string) error
+	}{
+		"memory": NewMemoryRegistry(),
+		"file":   fileRegistry,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := registry.Register(t.Context(), "alice", "vvccccfiluij"); err != nil {
+				t.Fatal(err)
+			}
+			if err := registry.Register(t.Context(), "alice", "vvccccfiluij"); err == nil {
+				t.Error("registered the same device twice")
+			}
+			if err := registry.Register(t.Context(), "alice", "not modhex"); err == nil {
+				t.Error("registered an invalid public identifier")
+			}
+			if ok, _ := registry.IsRegistered(t.Context(), "alice", "vvccccfiluij"); !ok {
+				t.Error("device is not registered")
+			}
+			if ok, _ := registry.IsRegistered(t.Context(), "bob", "vvccccfiluij"); ok {
+				t.Error("device is registered to the wrong user")
+			}
+		})
+	}
+
+	reloaded, err := NewFileRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := reloaded.IsRegistered(t.Context(), "alice", "vvccccfiluij"); !ok {
+		t.Error("device registration was not saved")
+	}
+}
+
+func TestAuthenticateUser(t *testing.T) {
+	registry := NewMemoryRegistry()
+	if err := registry.Register(t.Context(), "alice", "vvccccfiluij"); err != nil {
+		t.Fatal(err)
+	}
+	authenticator, err := New(
+		WithEndpoints(startStubServer(t, echoFields)),
+		WithRegistry(registry),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = authenticator.AuthenticateUser(t.Context(), "alice", stubRequest()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = authenticator.AuthenticateUser(t.Context(), "bob", stubRequest()); !errors.Is(err, ErrRegistryDeviceNotRegistered) {
+		t.Fatalf("expected error %v, got %v", ErrRegistryDeviceNotRegistered, err)
+	}
+}
@@ -0,0 +1,165 @@
+package yubikeyotp
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ReplayCounter holds the YubiKey counters of a one time password.
+// Every new one time password moves them forward.
+type ReplayCounter struct {
+	// Session is the non-volatile usage counter.
+	Session uint `json:"session"`
+	// Use is the counter of presses within the current power-up session.
+	Use uint `json:"use"`
+}
+
+// After returns true if the counter is ahead of the previous one.
+func (c ReplayCounter) After(previous ReplayCounter) bool {
+	if c.Session == previous.Session {
+		return c.Use > previous.Use
+	}
+	return c.Session > previous.Session
+}
+
+// ReplayStore remembers the latest counters of each YubiKey to reject
+// replayed one time passwords independently of validation servers,
+// which may each accept the same one time password when the
+// synchronization factor is low.
+type ReplayStore interface {
+	// Advance records the counter of the YubiKey. It must return
+	// [ErrRequestReplayed] if the counter does not move forward.
+	Advance(ctx context.Context, publicID string, counter ReplayCounter) error
+}
+
+var (
+	_ ReplayStore = (*MemoryReplayStore)(nil)
+	_ ReplayStore = (*FileReplayStore)(nil)
+)
+
+// MemoryReplayStore satisfies the [ReplayStore] interface by keeping
+// a limited number of the most recently used YubiKey counters in memory.
+//
+// WARNING: the store forgets counters after their time to live, when
+// they are evicted to make room for other YubiKeys, and on restart.
+// Any older one time password of a forgotten YubiKey is then accepted.
+// It only supplements the replay protection of validation servers
+// and must not be the sole guard, as it would be for a [LocalValidator].
+// Create only with [NewMemoryReplayStore] constructor.
+type MemoryReplayStore struct {
+	capacity int
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	recent  *list.List
+	entries map[string]*list.Element
+}
+
+type replayEntry struct {
+	PublicID   string
+	Counter    ReplayCounter
+	RecordedAt time.Time
+}
+
+// NewMemoryReplayStore creates a [MemoryReplayStore] that remembers up to
+// capacity YubiKeys, each for the duration of time to live since last use.
+func NewMemoryReplayStore(capacity int, ttl time.Duration) (*MemoryReplayStore, error) {
+	if capacity < 1 {
+		return nil, errors.New("replay store capacity must be greater than zero")
+	}
+	if ttl <= 0 {
+		return nil, errors.New("replay store time to live must be greater than zero")
+	}
+	return &MemoryReplayStore{
+		capacity: capacity,
+		ttl:      ttl,
+		recent:   list.New(),
+		entries:  make(map[string]*list.Element),
+	}, nil
+}
+
+func (s *MemoryReplayStore) Advance(_ context.Context, publicID string, counter ReplayCounter) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[publicID]; ok {
+		entry := element.Value.(*replayEntry)
+		if now.Sub(entry.RecordedAt) < s.ttl && !counter.After(entry.Counter) {
+			return ErrRequestReplayed
+		}
+		entry.Counter = counter
+		entry.RecordedAt = now
+		s.recent.MoveToFront(element)
+		return nil
+	}
+
+	s.entries[publicID] = s.recent.PushFront(&replayEntry{
+		PublicID:   publicID,
+		Counter:    counter,
+		RecordedAt: now,
+	})
+	for s.recent.Len() > s.capacity {
+		oldest := s.recent.Back()
+		delete(s.entries, oldest.Value.(*replayEntry).PublicID)
+		s.recent.Remove(oldest)
+	}
+	return nil
+}
+
+// FileReplayStore satisfies the [ReplayStore] interface by keeping
+// YubiKey counters in a JSON file, which survives restarts.
+// Create only with [NewFileReplayStore] constructor.
+type FileReplayStore struct {
+	path string
+
+	mu       sync.Mutex
+	counters map[string]ReplayCounter
+}
+
+// NewFileReplayStore creates a [FileReplayStore] that loads counters from
+// the JSON file at path. The file is created on first successful validation.
+func NewFileReplayStore(path string) (*FileReplayStore, error) {
+	s := &FileReplayStore{path: path, counters: make(map[string]ReplayCounter)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read replay store: %w", err)
+	}
+	if err = json.Unmarshal(data, &s.counters); err != nil {
+		return nil, fmt.Errorf("unable to decode replay store %q: %w", path, err)
+	}
+	return s, nil
+}
+
+func (s *FileReplayStore) Advance(_ context.Context, publicID string, counter ReplayCounter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, ok := s.counters[publicID]
+	if ok && !counter.After(previous) {
+		return ErrRequestReplayed
+	}
+	s.counters[publicID] = counter
+	data, err := json.Marshal(s.counters)
+	if err == nil {
+		err = writeFileAtomically(s.path, data)
+	}
+	if err != nil {
+		if ok {
+			s.counters[publicID] = previous
+		} else {
+			delete(s.counters, publicID)
+		}
+		return fmt.Errorf("unable to save replay store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package yubikeyotp
+
+import (
+	"errors"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReplayStores(t *testing.T) {
+	memory, err := NewMemoryReplayStore(10, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "counters.json")
+	file, err := NewFileReplayStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, store := range map[string]ReplayStore{
+		"memory": memory,
+		"file":   file,
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, tc := range []struct {
+				Counter  ReplayCounter
+				Expected error
+			}{
+				{ReplayCounter{Session: 1, Use: 1}, nil},
+				{ReplayCounter{Session: 1, Use: 1}, ErrRequestReplayed},
+				{ReplayCounter{Session: 1, Use: 0}, ErrRequestReplayed},
+				{ReplayCounter{Session: 0, Use: 9}, ErrRequestReplayed},
+				{ReplayCounter{Session: 1, Use: 2}, nil},
+				{ReplayCounter{Session: 2, Use: 0}, nil},
+			} {
+				if err := store.Advance(t.Context(), "vvccccfiluij", tc.Counter); !errors.Is(err, tc.Expected) {
+					t.Errorf("%+v: expected error %v, got %v", tc.Counter, tc.Expected, err)
+				}
+			}
+		})
+	}
+
+	reloaded, err := NewFileReplayStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = reloaded.Advance(t.Context(), "vvccccfiluij", ReplayCounter{Session: 2}); !errors.Is(err, ErrRequestReplayed) {
+		t.Errorf("counters were not saved: %v", err)
+	}
+}
+
+func TestMemoryReplayStoreEviction(t *testing.T) {
+	store, err := NewMemoryReplayStore(1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := ReplayCounter{Session: 5, Use: 5}
+	// forgotten YubiKeys accept older counters, which is why
+	// the local validator refuses this store
+	older := ReplayCounter{Session: 1}
+	_ = store.Advance(t.Context(), "vvccccfiluij", counter)
+	_ = store.Advance(t.Context(), "vvccccfiluik", counter)
+	if err = store.Advance(t.Context(), "vvccccfiluij", older); err != nil {
+		t.Errorf("least recently used key was not evicted: %v", err)
+	}
+
+	store, err = NewMemoryReplayStore(1, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = store.Advance(t.Context(), "vvccccfiluij", counter)
+	time.Sleep(time.Millisecond * 5)
+	if err = store.Advance(t.Context(), "vvccccfiluij", older); err != nil {
+		t.Errorf("expired key was not forgotten: %v", err)
+	}
+}
+
+func TestAuthenticatorReplayGuard(t *testing.T) {
+	store, err := NewMemoryReplayStore(10, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator, err := New(
+		WithEndpoints(startStubServer(t, echoFields)),
+		WithReplayStore(store),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = authenticator.Authenticate(t.Context(), stubRequest()); err != nil {
+		t.Fatal(err)
+	}
+	// the stub server accepts the same counters again
+	if err = authenticator.Authenticate(t.Context(), stubRequest()); !errors.Is(err, ErrRequestReplayed) {
+		t.Fatalf("expected error %v, got %v", ErrRequestReplayed, err)
+	}
+}
+
+func TestAuthenticatorReplayGuardRequiresCounters(t *testing.T) {
+	store, err := NewMemoryReplayStore(10, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"sessioncounter", "sessionuse"} {
+		authenticator, err := New(
+			WithEndpoints(startStubServer(t, func(query url.Values) map[string]string {
+				fields := echoFields(query)
+				delete(fields, field)
+				return fields
+			})),
+			WithReplayStore(store),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = authenticator.Authenticate(t.Context(), stubRequest()); !errors.Is(err, ErrResponseMissingCounters) {
+			t.Fatalf("response without %q field: expected error %v, got %v", field, ErrResponseMissingCounters, err)
+		}
+	}
+}
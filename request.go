@@ -17,7 +17,11 @@ type Request struct {
 	// ClientID identifies the authenticating user.
 	ClientID uint
 	// ClientSecret is the secret key for signing the request.
+	// Prefer [WithCredentialProvider] to passing secrets with each request.
 	ClientSecret string
+	// Tenant selects [Credentials] from the provider configured with
+	// [WithCredentialProvider]. It is ignored when ClientSecret is set.
+	Tenant string
 }
 
 func (a *Authenticator) buildSignedRequestQuery(
@@ -28,7 +32,7 @@ func (a *Authenticator) buildSignedRequestQuery(
 ) string {
 	b := bytes.Buffer{}
 	_, _ = b.WriteString("id=")
-	_, _ = b.WriteString(strconv.Itoa(int(clientID)))
+	_, _ = b.WriteString(strconv.FormatUint(uint64(clientID), 10))
 	// Nonce prevents replay attacks. Must contain 16 to 40 characters by specification.
 	_, _ = b.WriteString("&nonce=")
 	_, _ = b.WriteString(url.QueryEscape(nonce.String()))
@@ -46,7 +50,7 @@ func (a *Authenticator) buildSignedRequestQuery(
 	signature := hmac.New(sha1.New, secret)
 	_, _ = signature.Write(b.Bytes())
 	_, _ = b.WriteString("&h=")
-	_, _ = b.WriteString(base64.StdEncoding.EncodeToString(signature.Sum(nil)))
+	_, _ = b.WriteString(url.QueryEscape(base64.StdEncoding.EncodeToString(signature.Sum(nil))))
 
 	return b.String()
 }
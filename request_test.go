@@ -0,0 +1,65 @@
+package yubikeyotp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func FuzzBuildSignedRequestQuery(f *testing.F) {
+	f.Add(stubOneTimePassword, uint(stubClientID), stubClientSecret, []byte("nonce"))
+	f.Add(stubOneTimePassword[12:], uint(0), []byte{}, []byte{0xff})
+	f.Add(stubOneTimePassword, ^uint(0), stubClientSecret, []byte("nonce"))
+
+	authenticator, err := New()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Fuzz(func(t *testing.T, otp string, clientID uint, secret, seed []byte) {
+		if _, err := ParseOTP(otp); err != nil || len(seed) == 0 {
+			return // Verify rejects such one time passwords before signing
+		}
+		var nonce Nonce
+		for i := range nonce {
+			nonce[i] = defaultNonceCharacterSet[int(seed[i%len(seed)])%len(defaultNonceCharacterSet)]
+		}
+
+		query, err := url.ParseQuery(authenticator.buildSignedRequestQuery(otp, clientID, secret, nonce))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query.Get("otp") != otp || query.Get("nonce") != nonce.String() || query.Get("id") != strconv.FormatUint(uint64(clientID), 10) {
+			t.Fatalf("query does not carry request values: %v", query)
+		}
+
+		// a validation server checks the signature over decoded values
+		keys := make([]string, 0, len(query))
+		for key, values := range query {
+			if len(values) != 1 {
+				t.Fatalf("query repeats %q", key)
+			}
+			if key != "h" {
+				keys = append(keys, key)
+			}
+		}
+		slices.Sort(keys)
+		pairs := make([]string, len(keys))
+		for i, key := range keys {
+			pairs[i] = key + "=" + query.Get(key)
+		}
+		expected := hmac.New(sha1.New, secret)
+		_, _ = expected.Write([]byte(strings.Join(pairs, "&")))
+		received, err := base64.StdEncoding.DecodeString(query.Get("h"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !hmac.Equal(received, expected.Sum(nil)) {
+			t.Fatalf("request signature does not match query %v", query)
+		}
+	})
+}
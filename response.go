@@ -2,19 +2,21 @@ package yubikeyotp
 
 import (
 	"bufio"
+	"bytes"
 	"crypto/hmac"
 	"crypto/sha1"
 	"encoding/base64"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
 )
 
 type response struct {
 	// ReceivedOneTimePassword matches the provided by YubiKey touch in the Request.
 	ReceivedOneTimePassword string `query:"otp"`
-	// SignatureInBase64 (RFC 4648) encoded HMAC-SHA1 signature that validates the response.
-	SignatureInBase64 string `query:"h"`
+	// Signature is the decoded HMAC-SHA1 signature that validates the response.
+	Signature []byte `query:"h"`
 	// Nonce prevents replay attacks. Matches the nonce provded in the Request.
 	ReceivedNonce string `query:"nonce"`
 	// SessionCounter is the YubiKey internal usage counter when key was pressed.
@@ -29,17 +31,40 @@ type response struct {
 	RequestTimestamp string `query:"t"`
 	// ActivationTimestamp indicates when YubiKey was pressed.
 	ActivationTimestamp string `query:"timestamp"`
+	// Fields holds every received key-value pair in order, including
+	// the signature and fields unknown to this package.
+	Fields []responseField
 }
 
-// Verify checks the response status and signature. Returns `nil` is response is verified.
+type responseField struct {
+	Key   string
+	Value string
+}
+
+// Verify checks the response signature and status. Returns `nil` is response is verified.
+// Failure statuses must be signed too. Unsigned failure statuses are reported
+// as [UnverifiedStatusError], because anyone on the network path could forge them.
+// That includes BAD_SIGNATURE, which servers leave unsigned, so that a forged one
+// cannot stop validation by other endpoints; a genuine one repeats on every endpoint.
 func (r *response) Verify(secret []byte) (err error) {
+	signature := hmac.New(sha1.New, secret)
+	r.encodeForVerification(signature)
+	if !hmac.Equal(signature.Sum(nil), r.Signature) {
+		if r.Status != "OK" {
+			return UnverifiedStatusError(r.Status)
+		}
+		return ErrResponseBadSignature
+	}
+
 	switch r.Status {
 	case "OK":
-		// passed
+		return nil
 	case "BAD_OTP":
 		return ErrRequestInvalidFormat
-	case "REPLAYED_OTP", "REPLAYED_REQUEST":
+	case "REPLAYED_OTP":
 		return ErrRequestReplayed
+	case "REPLAYED_REQUEST":
+		return ErrRequestReplayedRequest
 	case "BAD_SIGNATURE":
 		return ErrRequestBadSignature
 	case "MISSING_PARAMETER":
@@ -55,43 +80,108 @@ func (r *response) Verify(secret []byte) (err error) {
 	default:
 		return ErrRequestUnknownFailure
 	}
+}
 
-	signature := hmac.New(sha1.New, secret)
-	r.encodeForVerification(signature)
-	if base64.StdEncoding.EncodeToString(signature.Sum(nil)) != r.SignatureInBase64 {
-		return ErrResponseBadSignature
+// VerifyBinding confirms that the response echoes the nonce and the one time password
+// of the request that produced it. Without this check, a validly signed response
+// to a different request could be replayed. Call only after [response.Verify].
+func (r *response) VerifyBinding(nonce Nonce, oneTimePassword string) error {
+	if r.ReceivedNonce != nonce.String() {
+		return ErrResponseNonceMismatch
+	}
+	if r.ReceivedOneTimePassword != oneTimePassword {
+		return ErrResponseOneTimePasswordMismatch
 	}
 	return nil
 }
 
-// encodeForVerification gathers response fields into a URLEncoded query for signature verification. Keys must be alphabetically sorted.
+// echoesRequest returns true if responses with the failure status
+// echo the nonce and the one time password of the request, so that
+// [response.VerifyBinding] applies to them as well as to OK responses.
+func echoesRequest(err RequestError) bool {
+	switch err {
+	case ErrRequestInvalidFormat, ErrRequestReplayed, ErrRequestClientDoesNotExist, ErrRequestForbidden:
+		return true
+	default:
+		return false
+	}
+}
+
+// encodeForVerification joins every received field except the signature
+// into a query for signature verification with keys sorted alphabetically,
+// as the specification requires. Fields unknown to this package are included,
+// so that protocol additions do not break verification.
 func (r *response) encodeForVerification(w io.Writer) {
-	_, _ = w.Write([]byte("nonce="))
-	_, _ = w.Write([]byte(r.ReceivedNonce))
-	_, _ = w.Write([]byte("&otp="))
-	_, _ = w.Write([]byte(r.ReceivedOneTimePassword))
-	_, _ = w.Write([]byte("&sessioncounter="))
-	_, _ = w.Write([]byte(r.SessionCounter))
-	_, _ = w.Write([]byte("&sessionuse="))
-	_, _ = w.Write([]byte(r.SessionUse))
-	_, _ = w.Write([]byte("&sl="))
-	_, _ = w.Write([]byte(r.SyncFactor))
-	_, _ = w.Write([]byte("&status="))
-	_, _ = w.Write([]byte(r.Status))
-	_, _ = w.Write([]byte("&t="))
-	_, _ = w.Write([]byte(r.RequestTimestamp))
-	_, _ = w.Write([]byte("&timestamp="))
-	_, _ = w.Write([]byte(r.ActivationTimestamp))
+	fields := make([]responseField, 0, len(r.Fields))
+	for _, field := range r.Fields {
+		if field.Key != "h" {
+			fields = append(fields, field)
+		}
+	}
+	slices.SortFunc(fields, func(a, b responseField) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	for i, field := range fields {
+		if i > 0 {
+			_, _ = w.Write([]byte("&"))
+		}
+		_, _ = w.Write([]byte(field.Key + "=" + field.Value))
+	}
 }
 
+// unknownFields returns fields that this package does not interpret.
+func (r *response) unknownFields() map[string]string {
+	var unknown map[string]string
+	for _, field := range r.Fields {
+		switch field.Key {
+		case "h", "t", "timestamp", "otp", "nonce", "sessioncounter", "sessionuse", "status", "sl":
+			continue
+		}
+		if unknown == nil {
+			unknown = make(map[string]string)
+		}
+		unknown[field.Key] = field.Value
+	}
+	return unknown
+}
+
+// maximumResponseSize limits the response body. Protocol responses
+// take a few hundred bytes.
+const maximumResponseSize = 4096
+
+// parseResponse reads protocol fields line by line. Responses that
+// repeat a field, contain a line that is not a key-value pair, or
+// exceed [maximumResponseSize] are rejected.
 func parseResponse(source io.Reader) (*response, error) {
+	body, err := io.ReadAll(io.LimitReader(source, maximumResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maximumResponseSize {
+		return nil, fmt.Errorf("response body exceeds %d bytes", maximumResponseSize)
+	}
+
 	r := &response{}
-	scanner := bufio.NewScanner(source)
+	seen := make(map[string]struct{})
+	scanner := bufio.NewScanner(bytes.NewReader(body))
 	for scanner.Scan() {
-		key, value, _ := strings.Cut(scanner.Text(), "=")
+		if scanner.Text() == "" {
+			continue // blank line
+		}
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("received a malformed API field %q", scanner.Text())
+		}
+		if _, ok = seen[key]; ok {
+			return nil, fmt.Errorf("received API field %q more than once", key)
+		}
+		seen[key] = struct{}{}
+		r.Fields = append(r.Fields, responseField{Key: key, Value: value})
 		switch key {
 		case "h":
-			r.SignatureInBase64 = value
+			if r.Signature, err = base64.StdEncoding.DecodeString(value); err != nil {
+				return nil, fmt.Errorf("invalid response signature encoding: %w", err)
+			}
 		case "t":
 			r.RequestTimestamp = value
 		case "timestamp":
@@ -108,10 +198,6 @@ func parseResponse(source io.Reader) (*response, error) {
 			r.Status = value
 		case "sl":
 			r.SyncFactor = value
-		default:
-			if value != "" {
-				return nil, fmt.Errorf("received an unexpected API field %q with value %q", key, value)
-			}
 		}
 	}
 	if err := scanner.Err(); err != nil {
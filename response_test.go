@@ -0,0 +1,242 @@
+package yubikeyotp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var testResponseSecret = []byte("test response secret")
+
+// signTestResponse is a reference signer that builds a response body
+// from key-value pairs signed over all of them sorted alphabetically.
+func signTestResponse(secret []byte, fields map[string]string) string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + fields[key]
+	}
+	signature := hmac.New(sha1.New, secret)
+	_, _ = signature.Write([]byte(strings.Join(pairs, "&")))
+	return "h=" + base64.StdEncoding.EncodeToString(signature.Sum(nil)) + "\r\n" +
+		strings.Join(pairs, "\r\n") + "\r\n\r\n"
+}
+
+func testResponseFields() map[string]string {
+	return map[string]string{
+		"nonce":          "0123456789abcdefghijABCDEFGHIJ0123456789",
+		"otp":            stubOneTimePassword,
+		"sessioncounter": "19",
+		"sessionuse":     "3",
+		"sl":             "100",
+		"status":         "OK",
+		"t":              "2007-01-09T14:21:49Z0493",
+		"timestamp":      "9127",
+	}
+}
+
+func TestParseResponseRejectsHostileBodies(t *testing.T) {
+	valid := signTestResponse(testResponseSecret, testResponseFields())
+	for name, body := range map[string]string{
+		"duplicate status":    valid + "status=OK\r\n",
+		"duplicate signature": "h=AAAA\r\n" + valid,
+		"bad encoding":        strings.Replace(valid, "h=", "h=!", 1),
+		"oversized":           valid + strings.Repeat("x", maximumResponseSize),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseResponse(strings.NewReader(body)); err == nil {
+				t.Fatal("hostile response body was accepted")
+			}
+		})
+	}
+
+	r, err := parseResponse(strings.NewReader(valid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = r.Verify(testResponseSecret); err != nil {
+		t.Fatal(err)
+	}
+	r.Signature = r.Signature[:len(r.Signature)-1]
+	if err = r.Verify(testResponseSecret); !errors.Is(err, ErrResponseBadSignature) {
+		t.Fatalf("truncated signature was accepted: %v", err)
+	}
+}
+
+func TestVerifyFailureStatusSignatures(t *testing.T) {
+	for status, expected := range map[string]error{
+		"REPLAYED_OTP":   ErrRequestReplayed,
+		"NO_SUCH_CLIENT": ErrRequestClientDoesNotExist,
+		"BACKEND_ERROR":  ErrRequestBackendError,
+		"BAD_SIGNATURE":  ErrRequestBadSignature,
+	} {
+		t.Run(status, func(t *testing.T) {
+			fields := map[string]string{"status": status, "t": "2007-01-09T14:21:49Z0493"}
+			signed, err := parseResponse(strings.NewReader(signTestResponse(testResponseSecret, fields)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = signed.Verify(testResponseSecret); err != expected {
+				t.Fatalf("expected error %v, got %v", expected, err)
+			}
+
+			forged, err := parseResponse(strings.NewReader(signTestResponse([]byte("forged"), fields)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = forged.Verify(testResponseSecret)
+			var requestError RequestError
+			if errors.As(err, &requestError) {
+				t.Fatalf("forged status was trusted: %v", err)
+			}
+			if err != UnverifiedStatusError(status) || !errors.Is(err, ErrResponseBadSignature) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+
+	unsigned, err := parseResponse(strings.NewReader("status=BAD_SIGNATURE\r\nt=2007-01-09T14:21:49Z0493\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// servers leave BAD_SIGNATURE unsigned, so it cannot be told apart from a forgery
+	if err = unsigned.Verify(testResponseSecret); err != UnverifiedStatusError("BAD_SIGNATURE") {
+		t.Fatalf("unsigned BAD_SIGNATURE status was trusted: %v", err)
+	}
+}
+
+func TestVerifyCoversEveryReceivedField(t *testing.T) {
+	fields := testResponseFields()
+	fields["sessioncounter"] = ""
+	fields["zone"] = "eu-west"
+	body := signTestResponse(testResponseSecret, fields)
+	r, err := parseResponse(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = r.Verify(testResponseSecret); err != nil {
+		t.Fatal(err)
+	}
+	result, err := newResult(r, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Extra) != 1 || result.Extra["zone"] != "eu-west" {
+		t.Fatalf("unexpected extra fields: %v", result.Extra)
+	}
+
+	for name, tampered := range map[string]string{
+		"changed unknown field": strings.Replace(body, "zone=eu-west", "zone=us-east", 1),
+		"added field":           body + "injected=1\r\n",
+		"removed empty field":   strings.Replace(body, "sessioncounter=\r\n", "", 1),
+	} {
+		r, err := parseResponse(strings.NewReader(tampered))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = r.Verify(testResponseSecret); !errors.Is(err, ErrResponseBadSignature) {
+			t.Errorf("%s: expected error %v, got %v", name, ErrResponseBadSignature, err)
+		}
+	}
+}
+
+func FuzzParseResponse(f *testing.F) {
+	f.Add(signTestResponse(testResponseSecret, testResponseFields()))
+	f.Add("h=\r\nstatus=REPLAYED_OTP\r\n")
+	f.Add("status=OK\r\nstatus=OK\r\n")
+	f.Add("=\r\n\r\n")
+
+	f.Fuzz(func(t *testing.T, body string) {
+		r, err := parseResponse(strings.NewReader(body))
+		if err != nil {
+			return
+		}
+		if r.Verify(testResponseSecret) != nil {
+			return
+		}
+		// only the reference signer can produce an accepted response
+		fields := make(map[string]string)
+		for _, line := range strings.Split(body, "\n") {
+			key, value, _ := strings.Cut(strings.TrimSuffix(line, "\r"), "=")
+			if key != "" && key != "h" {
+				fields[key] = value
+			}
+		}
+		expected := signTestResponse(testResponseSecret, fields)
+		if !strings.HasPrefix(expected, "h="+base64.StdEncoding.EncodeToString(r.Signature)+"\r\n") {
+			t.Fatalf("response was accepted without a valid signature: %q", body)
+		}
+	})
+}
+
+func FuzzVerify(f *testing.F) {
+	f.Add(signTestResponse(testResponseSecret, testResponseFields()), 0, byte(1))
+	f.Add(signTestResponse(testResponseSecret, testResponseFields()), 100, byte(0x20))
+
+	f.Fuzz(func(t *testing.T, body string, position int, mask byte) {
+		if mask == 0 || position < 0 || position >= len(body) {
+			return
+		}
+		mutated := []byte(body)
+		mutated[position] ^= mask
+		r, err := parseResponse(strings.NewReader(string(mutated)))
+		if err != nil {
+			return
+		}
+		original, err := parseResponse(strings.NewReader(body))
+		if err != nil || original.Verify(testResponseSecret) != nil {
+			return
+		}
+		if reflect.DeepEqual(r, original) {
+			return // the mutation did not change any field, such as a line ending
+		}
+		if r.Verify(testResponseSecret) == nil {
+			t.Fatalf("mutated response was accepted: %q", mutated)
+		}
+	})
+}
+
+func FuzzSignedResponseVerifies(f *testing.F) {
+	fields := testResponseFields()
+	f.Add(fields["nonce"], fields["otp"], fields["sessioncounter"], fields["sl"], fields["t"], "extension", []byte("secret"))
+	f.Add("", "", "", "", "", "", []byte{})
+
+	f.Fuzz(func(t *testing.T, nonce, otp, counter, sl, timestamp, extra string, secret []byte) {
+		fields := map[string]string{
+			"extra":          extra,
+			"nonce":          nonce,
+			"otp":            otp,
+			"sessioncounter": counter,
+			"sessionuse":     "0",
+			"sl":             sl,
+			"status":         "OK",
+			"t":              timestamp,
+			"timestamp":      "0",
+		}
+		for _, value := range fields {
+			if strings.ContainsAny(value, "\r\n") {
+				return // values cannot span lines
+			}
+		}
+		body := signTestResponse(secret, fields)
+		if len(body) > maximumResponseSize {
+			return
+		}
+		r, err := parseResponse(strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = r.Verify(secret); err != nil {
+			t.Fatalf("response signed by the reference signer was rejected: %v", err)
+		}
+	})
+}
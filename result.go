@@ -0,0 +1,100 @@
+package yubikeyotp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Result holds the verified details of a successful
+// one-time password validation.
+type Result struct {
+	// PublicID is the modhex identifier of the YubiKey that produced the one-time password.
+	PublicID string
+	// SessionCounter is the YubiKey non-volatile usage counter when the key was pressed.
+	SessionCounter uint
+	// SessionUse is the YubiKey volatile counter of presses within the current power-up session.
+	SessionUse uint
+	// ActivationTimestamp is the YubiKey internal 8Hz clock value when the key was pressed.
+	ActivationTimestamp uint
+	// SyncPercent from 0 to 100 indicates the percentage of validation servers that agreed on the response.
+	SyncPercent uint8
+	// Time is the server time of the response in UTC.
+	Time time.Time
+	// Endpoint is the API endpoint that answered the request.
+	Endpoint string
+	// Extra holds signed response fields that this package does not interpret,
+	// such as protocol additions or extensions of self-hosted servers.
+	// It is nil, if there are none.
+	Extra map[string]string
+}
+
+func newResult(r *response, endpoint string) (_ *Result, err error) {
+	result := &Result{
+		PublicID: publicIDFromOneTimePassword(r.ReceivedOneTimePassword),
+		Endpoint: endpoint,
+		Extra:    r.unknownFields(),
+	}
+	if result.SessionCounter, err = parseUintField("sessioncounter", r.SessionCounter, 16); err != nil {
+		return nil, err
+	}
+	if result.SessionUse, err = parseUintField("sessionuse", r.SessionUse, 8); err != nil {
+		return nil, err
+	}
+	if result.ActivationTimestamp, err = parseUintField("timestamp", r.ActivationTimestamp, 24); err != nil {
+		return nil, err
+	}
+	syncPercent, err := parseUintField("sl", r.SyncFactor, 8)
+	if err != nil {
+		return nil, err
+	}
+	if syncPercent > 100 {
+		return nil, fmt.Errorf("synchronization level %d is greater than 100", syncPercent)
+	}
+	result.SyncPercent = uint8(syncPercent)
+	if result.Time, err = parseResponseTime(r.RequestTimestamp); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// publicIDFromOneTimePassword returns the characters preceding the 32-character encrypted token.
+func publicIDFromOneTimePassword(otp string) string {
+	if len(otp) <= 32 {
+		return ""
+	}
+	return otp[:len(otp)-32]
+}
+
+func parseUintField(name, value string, bitSize int) (uint, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(value, 10, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %q field value %q: %w", name, value, err)
+	}
+	return uint(n), nil
+}
+
+// parseResponseTime decodes the "t" field, which looks like
+// "2007-01-09T14:21:49Z0493" where the trailing digits are milliseconds.
+func parseResponseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	datetime, milliseconds, _ := strings.Cut(value, "Z")
+	t, err := time.ParseInLocation("2006-01-02T15:04:05", datetime, time.UTC)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %q field value %q: %w", "t", value, err)
+	}
+	if milliseconds != "" {
+		ms, err := strconv.ParseUint(milliseconds, 10, 16)
+		if err != nil || ms > 999 {
+			return time.Time{}, fmt.Errorf("invalid %q field value %q: bad milliseconds", "t", value)
+		}
+		t = t.Add(time.Duration(ms) * time.Millisecond)
+	}
+	return t, nil
+}
@@ -0,0 +1,49 @@
+package yubikeyotp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResultFromResponse(t *testing.T) {
+	result, err := newResult(&response{
+		ReceivedOneTimePassword: "vvccccfiluijkvkjghvtjcjcclfbvjhclrrkbetebghv",
+		SessionCounter:          "42",
+		SessionUse:              "3",
+		SyncFactor:              "75",
+		RequestTimestamp:        "2007-01-09T14:21:49Z0493",
+		ActivationTimestamp:     "12345",
+	}, "https://example.com/wsapi/2.0/verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.PublicID != "vvccccfiluij" {
+		t.Errorf("unexpected public ID: %q", result.PublicID)
+	}
+	if result.SessionCounter != 42 || result.SessionUse != 3 || result.ActivationTimestamp != 12345 {
+		t.Errorf("unexpected counters: %+v", result)
+	}
+	if result.SyncPercent != 75 {
+		t.Errorf("unexpected synchronization percentage: %d", result.SyncPercent)
+	}
+	if expected := time.Date(2007, 1, 9, 14, 21, 49, 493*int(time.Millisecond), time.UTC); !result.Time.Equal(expected) {
+		t.Errorf("unexpected time: %s", result.Time)
+	}
+	if result.Endpoint != "https://example.com/wsapi/2.0/verify" {
+		t.Errorf("unexpected endpoint: %q", result.Endpoint)
+	}
+}
+
+func TestResultRejectsInvalidFields(t *testing.T) {
+	for name, r := range map[string]*response{
+		"counter":  {SessionCounter: "-1"},
+		"use":      {SessionUse: "256"},
+		"sync":     {SyncFactor: "101"},
+		"time":     {RequestTimestamp: "yesterday"},
+		"millisec": {RequestTimestamp: "2007-01-09T14:21:49Z9999"},
+	} {
+		if _, err := newResult(r, ""); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package yubikeyotp
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy decides whether and when to try again after a failed attempt.
+type RetryPolicy interface {
+	// NextRetryDelay returns the delay before the next attempt given the
+	// number of attempts made so far, starting with one, and the previous
+	// delay, which is zero after the first attempt. It returns false when
+	// no more attempts should be made.
+	NextRetryDelay(attempt int, previous time.Duration) (time.Duration, bool)
+}
+
+var (
+	_ RetryPolicy = RetryWithBackOff{}
+	_ RetryPolicy = RetryWithFullJitter{}
+	_ RetryPolicy = RetryWithDecorrelatedJitter{}
+)
+
+// RetryWithBackOff is an exponential [RetryPolicy]. The delay starts at
+// AttemptDelay and grows by AttemptDelayMultiplier after every attempt
+// up to AttemptDelayLimit.
+type RetryWithBackOff struct {
+	AttemptLimit           uint8
+	AttemptDelay           time.Duration
+	AttemptDelayLimit      time.Duration
+	AttemptDelayMultiplier float64
+}
+
+func (r RetryWithBackOff) NextRetryDelay(attempt int, _ time.Duration) (time.Duration, bool) {
+	if attempt >= int(r.AttemptLimit) {
+		return 0, false
+	}
+	return exponentialDelay(r.AttemptDelay, r.AttemptDelayLimit, r.AttemptDelayMultiplier, attempt), true
+}
+
+func (r RetryWithBackOff) validate() error {
+	if err := validateRetryDelays(r.AttemptLimit, r.AttemptDelay, r.AttemptDelayLimit); err != nil {
+		return err
+	}
+	return validateRetryMultiplier(r.AttemptDelayMultiplier)
+}
+
+// RetryWithFullJitter is an exponential [RetryPolicy] that waits a random
+// duration between zero and the delay of [RetryWithBackOff] with the same
+// settings. Randomness spreads out retries of many clients that failed
+// at the same time.
+type RetryWithFullJitter struct {
+	AttemptLimit           uint8
+	AttemptDelay           time.Duration
+	AttemptDelayLimit      time.Duration
+	AttemptDelayMultiplier float64
+}
+
+func (r RetryWithFullJitter) NextRetryDelay(attempt int, _ time.Duration) (time.Duration, bool) {
+	if attempt >= int(r.AttemptLimit) {
+		return 0, false
+	}
+	limit := exponentialDelay(r.AttemptDelay, r.AttemptDelayLimit, r.AttemptDelayMultiplier, attempt)
+	return rand.N(limit + 1), true
+}
+
+func (r RetryWithFullJitter) validate() error {
+	if err := validateRetryDelays(r.AttemptLimit, r.AttemptDelay, r.AttemptDelayLimit); err != nil {
+		return err
+	}
+	return validateRetryMultiplier(r.AttemptDelayMultiplier)
+}
+
+// RetryWithDecorrelatedJitter is a [RetryPolicy] that waits a random duration
+// between AttemptDelay and three times the previous delay, never exceeding
+// AttemptDelayLimit.
+type RetryWithDecorrelatedJitter struct {
+	AttemptLimit      uint8
+	AttemptDelay      time.Duration
+	AttemptDelayLimit time.Duration
+}
+
+func (r RetryWithDecorrelatedJitter) NextRetryDelay(attempt int, previous time.Duration) (time.Duration, bool) {
+	if attempt >= int(r.AttemptLimit) {
+		return 0, false
+	}
+	upper := max(previous*3, r.AttemptDelay)
+	delay := r.AttemptDelay + rand.N(upper-r.AttemptDelay+1)
+	return min(delay, r.AttemptDelayLimit), true
+}
+
+func (r RetryWithDecorrelatedJitter) validate() error {
+	return validateRetryDelays(r.AttemptLimit, r.AttemptDelay, r.AttemptDelayLimit)
+}
+
+// exponentialDelay returns initial delay multiplied
+// by multiplier once per attempt after the first, up to the limit.
+func exponentialDelay(initial, limit time.Duration, multiplier float64, attempt int) time.Duration {
+	delay := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if delay >= float64(limit) {
+		return limit
+	}
+	return time.Duration(delay)
+}
+
+func validateRetryDelays(attemptLimit uint8, delay, delayLimit time.Duration) error {
+	if attemptLimit == 0 {
+		return errors.New("retry limit must be greater than zero")
+	}
+	if delay <= time.Millisecond*30 {
+		return errors.New("retry delay must be greater than 30 milliseconds")
+	}
+	if delay > time.Minute {
+		return errors.New("retry delay must be less than one minute")
+	}
+	if delayLimit < delay {
+		return errors.New("retry delay limit must be greater than retry delay")
+	}
+	return nil
+}
+
+func validateRetryMultiplier(multiplier float64) error {
+	if multiplier <= 1 {
+		return errors.New("retry multiplier must be greater than one")
+	}
+	if multiplier > 10 {
+		return errors.New("retry multiplier must be less than 10")
+	}
+	return nil
+}
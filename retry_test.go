@@ -0,0 +1,118 @@
+package yubikeyotp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryWithBackOffDelays(t *testing.T) {
+	policy := RetryWithBackOff{
+		AttemptLimit:           6,
+		AttemptDelay:           time.Second,
+		AttemptDelayLimit:      time.Second * 3,
+		AttemptDelayMultiplier: 1.5,
+	}
+	for _, tc := range []struct {
+		Attempt  int
+		Expected time.Duration
+		Retry    bool
+	}{
+		{1, time.Second, true},
+		{2, time.Millisecond * 1500, true},
+		{3, time.Millisecond * 2250, true},
+		{4, time.Second * 3, true}, // 3.375s capped
+		{5, time.Second * 3, true},
+		{6, 0, false},
+	} {
+		delay, retry := policy.NextRetryDelay(tc.Attempt, 0)
+		if delay != tc.Expected || retry != tc.Retry {
+			t.Errorf("attempt %d: expected %s %t, got %s %t", tc.Attempt, tc.Expected, tc.Retry, delay, retry)
+		}
+	}
+}
+
+func TestRetryWithJitterDelays(t *testing.T) {
+	for _, tc := range []struct {
+		Name    string
+		Policy  RetryPolicy
+		Attempt int
+		Lower   time.Duration
+		Upper   time.Duration
+	}{
+		{
+			Name: "full jitter first attempt",
+			Policy: RetryWithFullJitter{
+				AttemptLimit: 5, AttemptDelay: time.Second,
+				AttemptDelayLimit: time.Second * 10, AttemptDelayMultiplier: 2,
+			},
+			Attempt: 1, Lower: 0, Upper: time.Second,
+		},
+		{
+			Name: "full jitter grows",
+			Policy: RetryWithFullJitter{
+				AttemptLimit: 5, AttemptDelay: time.Second,
+				AttemptDelayLimit: time.Second * 10, AttemptDelayMultiplier: 2,
+			},
+			Attempt: 3, Lower: 0, Upper: time.Second * 4,
+		},
+		{
+			Name: "full jitter is capped",
+			Policy: RetryWithFullJitter{
+				AttemptLimit: 10, AttemptDelay: time.Second,
+				AttemptDelayLimit: time.Second * 10, AttemptDelayMultiplier: 2,
+			},
+			Attempt: 8, Lower: 0, Upper: time.Second * 10,
+		},
+		{
+			Name: "decorrelated jitter",
+			Policy: RetryWithDecorrelatedJitter{
+				AttemptLimit: 5, AttemptDelay: time.Second, AttemptDelayLimit: time.Second * 10,
+			},
+			Attempt: 2, Lower: time.Second, Upper: time.Second * 6,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			seen := make(map[time.Duration]struct{})
+			for range 1000 {
+				delay, retry := tc.Policy.NextRetryDelay(tc.Attempt, time.Second*2)
+				if !retry {
+					t.Fatal("policy stopped retrying too early")
+				}
+				if delay < tc.Lower || delay > tc.Upper {
+					t.Fatalf("delay %s is outside of [%s, %s]", delay, tc.Lower, tc.Upper)
+				}
+				seen[delay] = struct{}{}
+			}
+			if len(seen) < 100 {
+				t.Errorf("delays are not random enough: %d distinct values", len(seen))
+			}
+		})
+	}
+}
+
+func TestDecorrelatedJitterIsCapped(t *testing.T) {
+	policy := RetryWithDecorrelatedJitter{
+		AttemptLimit: 3, AttemptDelay: time.Second, AttemptDelayLimit: time.Second * 2,
+	}
+	for range 1000 {
+		if delay, _ := policy.NextRetryDelay(1, time.Minute); delay > time.Second*2 {
+			t.Fatalf("delay %s exceeds the limit", delay)
+		}
+	}
+	if _, retry := policy.NextRetryDelay(3, time.Second); retry {
+		t.Fatal("policy did not stop at attempt limit")
+	}
+}
+
+func TestRetryPolicyValidation(t *testing.T) {
+	for name, policy := range map[string]RetryPolicy{
+		"zero attempts":      RetryWithBackOff{AttemptDelay: time.Second, AttemptDelayLimit: time.Second, AttemptDelayMultiplier: 2},
+		"small multiplier":   RetryWithFullJitter{AttemptLimit: 1, AttemptDelay: time.Second, AttemptDelayLimit: time.Second, AttemptDelayMultiplier: 1},
+		"limit below delay":  RetryWithDecorrelatedJitter{AttemptLimit: 1, AttemptDelay: time.Second, AttemptDelayLimit: time.Millisecond * 500},
+		"tiny initial delay": RetryWithBackOff{AttemptLimit: 1, AttemptDelay: time.Millisecond, AttemptDelayLimit: time.Second, AttemptDelayMultiplier: 2},
+	} {
+		if _, err := New(WithRetryPolicy(policy)); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+/*
+Package server provides a [http.Handler] that implements
+Yubico validation protocol version 2.0, which is served by
+the official API at /wsapi/2.0/verify. Point
+[yubikeyotp.WithEndpoints] at the handler to run a self-hosted
+validation service. Unlike the specification, the handler requires
+clients to sign requests.
+
+Protocol documentation: <https://developers.yubico.com/yubikey-val/Validation_Protocol_V2.0.html>
+*/
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dkotik/yubikeyotp"
+)
+
+// ClientStore provides API keys of clients allowed to use the validation service.
+// Return [yubikeyotp.ErrRequestClientDoesNotExist] for unknown clients. For clients
+// that are not allowed to verify one time passwords, return the key together with
+// [yubikeyotp.ErrRequestForbidden], so that the refusal can be signed.
+type ClientStore interface {
+	LookupClientKey(ctx context.Context, clientID uint) ([]byte, error)
+}
+
+// ClientKeys satisfies the [ClientStore] interface using
+// decoded API keys indexed by client identifiers.
+type ClientKeys map[uint][]byte
+
+func (c ClientKeys) LookupClientKey(_ context.Context, clientID uint) ([]byte, error) {
+	key, ok := c[clientID]
+	if !ok {
+		return nil, yubikeyotp.ErrRequestClientDoesNotExist
+	}
+	return key, nil
+}
+
+// Handler answers validation requests with signed responses.
+// Create only with [New] constructor.
+type Handler struct {
+	clients  ClientStore
+	verifier yubikeyotp.Verifier
+
+	mu sync.Mutex
+	// lastRequests holds the latest accepted one time password and nonce
+	// for each YubiKey public identifier to detect replayed requests.
+	lastRequests map[string]string
+}
+
+// New creates a validation protocol [Handler] that authenticates
+// clients using the store and validates one time passwords using
+// the verifier, which is usually a [yubikeyotp.LocalValidator].
+func New(clients ClientStore, verifier yubikeyotp.Verifier) (*Handler, error) {
+	if clients == nil {
+		return nil, errors.New("unable to initialize Yubi Key validation server: client store is nil")
+	}
+	if verifier == nil {
+		return nil, errors.New("unable to initialize Yubi Key validation server: verifier is nil")
+	}
+	return &Handler{
+		clients:      clients,
+		verifier:     verifier,
+		lastRequests: make(map[string]string),
+	}, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query := r.Form
+
+	clientID, err := strconv.ParseUint(query.Get("id"), 10, 0)
+	if err != nil {
+		writeResponse(w, nil, statusFields(yubikeyotp.ErrRequestMissingParameter, query))
+		return
+	}
+	key, err := h.clients.LookupClientKey(r.Context(), uint(clientID))
+	if err != nil && key == nil {
+		// without a client key the response cannot be signed
+		writeResponse(w, nil, statusFields(err, query))
+		return
+	}
+	if verifyRequestSignature(key, query) != nil {
+		// a client that cannot sign properly cannot check the response signature either
+		writeResponse(w, nil, statusFields(yubikeyotp.ErrRequestBadSignature, query))
+		return
+	}
+	if err != nil {
+		// the client is known but not authorized
+		writeResponse(w, key, statusFields(err, query))
+		return
+	}
+
+	fields, err := h.validate(r.Context(), query)
+	if err != nil {
+		writeResponse(w, key, statusFields(err, query))
+		return
+	}
+	writeResponse(w, key, fields)
+}
+
+func (h *Handler) validate(ctx context.Context, query url.Values) (map[string]string, error) {
+	otp := query.Get("otp")
+	nonce := query.Get("nonce")
+	if otp == "" || !isValidNonce(nonce) {
+		return nil, yubikeyotp.ErrRequestMissingParameter
+	}
+	parsed, err := yubikeyotp.ParseOTP(otp)
+	if err != nil {
+		return nil, err
+	}
+	if sl := query.Get("sl"); sl != "" && sl != "fast" && sl != "secure" {
+		if percent, err := strconv.ParseUint(sl, 10, 8); err != nil || percent > 100 {
+			return nil, yubikeyotp.ErrRequestMissingParameter
+		}
+	}
+	if timeout := query.Get("timeout"); timeout != "" {
+		seconds, err := strconv.ParseUint(timeout, 10, 16)
+		if err != nil {
+			return nil, yubikeyotp.ErrRequestMissingParameter
+		}
+		if seconds > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(seconds)*time.Second)
+			defer cancel()
+		}
+	}
+
+	publicID := parsed.PublicID
+	h.mu.Lock()
+	replayed := h.lastRequests[publicID] == otp+"&"+nonce
+	h.mu.Unlock()
+	if replayed {
+		return nil, yubikeyotp.ErrRequestReplayedRequest
+	}
+	// the lock is not held during validation, so that a slow verifier
+	// does not stall requests for other YubiKeys; concurrent requests
+	// with the same one time password are rejected by the verifier
+	result, err := h.verifier.Verify(ctx, yubikeyotp.Request{OneTimePassword: otp})
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, yubikeyotp.ErrRequestDeadlineExceeded
+		}
+		return nil, err
+	}
+	h.mu.Lock()
+	h.lastRequests[publicID] = otp + "&" + nonce
+	h.mu.Unlock()
+
+	fields := statusFields(nil, query)
+	if query.Get("timestamp") == "1" {
+		fields["sessioncounter"] = strconv.FormatUint(uint64(result.SessionCounter), 10)
+		fields["sessionuse"] = strconv.FormatUint(uint64(result.SessionUse), 10)
+		fields["timestamp"] = strconv.FormatUint(uint64(result.ActivationTimestamp), 10)
+	}
+	return fields, nil
+}
+
+// isValidNonce checks that the nonce contains 16 to 40 alphanumeric characters.
+func isValidNonce(nonce string) bool {
+	if l := len(nonce); l < 16 || l > 40 {
+		return false
+	}
+	for _, c := range []byte(nonce) {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func verifyRequestSignature(key []byte, query url.Values) error {
+	received := query.Get("h")
+	if received == "" {
+		// although the specification makes the signature optional,
+		// unsigned requests let anyone spend one time passwords
+		// on behalf of the client
+		return yubikeyotp.ErrRequestBadSignature
+	}
+	// unescaped "+" characters of base64 encoding turn into spaces
+	expected, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(received, " ", "+"))
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	fields := make(map[string]string, len(query))
+	for k := range query {
+		if k != "h" {
+			fields[k] = query.Get(k)
+		}
+	}
+	if !hmac.Equal(expected, sign(key, fields)) {
+		return yubikeyotp.ErrRequestBadSignature
+	}
+	return nil
+}
+
+// statusFields prepares response fields reporting the outcome.
+// Nil error reports status OK. The one time password and the nonce
+// are echoed only if they are well formed, so that the request
+// cannot inject fields into the response.
+func statusFields(err error, query url.Values) map[string]string {
+	status := "OK"
+	if err != nil {
+		var requestError yubikeyotp.RequestError
+		switch {
+		case errors.As(err, &requestError):
+			status = requestError.Status()
+		case errors.Is(err, yubikeyotp.ErrRequestInvalidFormat):
+			status = yubikeyotp.ErrRequestInvalidFormat.Status()
+		default:
+			status = yubikeyotp.ErrRequestBackendError.Status()
+		}
+	}
+	fields := map[string]string{
+		"status": status,
+		"t":      formatResponseTime(time.Now()),
+	}
+	if otp := query.Get("otp"); otp != "" {
+		if _, err := yubikeyotp.ParseOTP(otp); err == nil {
+			fields["otp"] = otp
+		}
+	}
+	if nonce := query.Get("nonce"); isValidNonce(nonce) {
+		fields["nonce"] = nonce
+	}
+	if status == "OK" {
+		// a single server is always fully synchronized with itself
+		fields["sl"] = "100"
+	}
+	return fields
+}
+
+// formatResponseTime encodes time like "2007-01-09T14:21:49Z0493"
+// where the trailing digits are milliseconds.
+func formatResponseTime(t time.Time) string {
+	t = t.UTC()
+	return fmt.Sprintf("%sZ0%03d", t.Format("2006-01-02T15:04:05"), t.Nanosecond()/int(time.Millisecond))
+}
+
+// sign computes HMAC-SHA1 over alphabetically sorted key-value pairs.
+func sign(key []byte, fields map[string]string) []byte {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	signature := hmac.New(sha1.New, key)
+	for i, k := range keys {
+		if i > 0 {
+			_, _ = signature.Write([]byte("&"))
+		}
+		_, _ = signature.Write([]byte(k + "=" + fields[k]))
+	}
+	return signature.Sum(nil)
+}
+
+// writeResponse sends the fields line by line. The response
+// is signed if the key is not nil. Fields that would span lines
+// are refused with an HTTP error.
+func writeResponse(w http.ResponseWriter, key []byte, fields map[string]string) {
+	keys := make([]string, 0, len(fields))
+	for k, v := range fields {
+		if strings.ContainsAny(k+v, "\r\n") {
+			http.Error(w, "response field contains a line break", http.StatusInternalServerError)
+			return
+		}
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	b := strings.Builder{}
+	if key != nil {
+		_, _ = b.WriteString("h=")
+		_, _ = b.WriteString(base64.StdEncoding.EncodeToString(sign(key, fields)))
+		_, _ = b.WriteString("\r\n")
+	}
+	for _, k := range keys {
+		_, _ = b.WriteString(k)
+		_, _ = b.WriteString("=")
+		_, _ = b.WriteString(fields[k])
+		_, _ = b.WriteString("\r\n")
+	}
+	_, _ = b.WriteString("\r\n")
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write([]byte(b.String()))
+}
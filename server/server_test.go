@@ -0,0 +1,288 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dkotik/yubikeyotp"
+)
+
+const (
+	testClientID        = 42
+	testOneTimePassword = "vvccccfiluijkvkjghvtjcjcclfbvjhclrrkbetebghv"
+	testNonce           = "abcdefghijklmnopqrstuvwxyz0123"
+)
+
+var testClientKey = []byte("test client key")
+
+type verifierFunc func(context.Context, yubikeyotp.Request) (*yubikeyotp.Result, error)
+
+func (f verifierFunc) Verify(ctx context.Context, r yubikeyotp.Request) (*yubikeyotp.Result, error) {
+	return f(ctx, r)
+}
+
+func newTestServer(t *testing.T, verifier yubikeyotp.Verifier) *httptest.Server {
+	t.Helper()
+	handler, err := New(ClientKeys{testClientID: testClientKey}, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func acceptAll(_ context.Context, r yubikeyotp.Request) (*yubikeyotp.Result, error) {
+	return &yubikeyotp.Result{
+		PublicID:       r.OneTimePassword[:12],
+		SessionCounter: 7,
+		SessionUse:     3,
+	}, nil
+}
+
+func TestAuthenticatorAgainstServer(t *testing.T) {
+	server := newTestServer(t, verifierFunc(acceptAll))
+	authenticator, err := yubikeyotp.New(yubikeyotp.WithEndpoints(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := authenticator.Verify(t.Context(), yubikeyotp.Request{
+		OneTimePassword: testOneTimePassword,
+		ClientID:        testClientID,
+		ClientSecret:    base64.StdEncoding.EncodeToString(testClientKey),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SessionCounter != 7 || result.SessionUse != 3 || result.SyncPercent != 100 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	// without a client key the server cannot sign the answer
+	ctx, cancel := context.WithTimeout(t.Context(), time.Millisecond*100)
+	defer cancel()
+	err = authenticator.Authenticate(ctx, yubikeyotp.Request{
+		OneTimePassword: testOneTimePassword,
+		ClientID:        testClientID + 1,
+		ClientSecret:    base64.StdEncoding.EncodeToString(testClientKey),
+	})
+	if !errors.Is(err, yubikeyotp.UnverifiedStatusError("NO_SUCH_CLIENT")) {
+		t.Errorf("expected unverified unknown client error, got %v", err)
+	}
+	if errors.Is(err, yubikeyotp.ErrRequestClientDoesNotExist) {
+		t.Error("unsigned status was trusted")
+	}
+}
+
+func query(t *testing.T, server *httptest.Server, signWith []byte, fields map[string]string) map[string]string {
+	t.Helper()
+	values := url.Values{}
+	for k, v := range fields {
+		values.Set(k, v)
+	}
+	if signWith != nil {
+		values.Set("h", base64.StdEncoding.EncodeToString(sign(signWith, fields)))
+	}
+	response, err := http.Get(server.URL + "?" + values.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	received := make(map[string]string)
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(scanner.Text(), "="); ok {
+			received[key] = value
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return received
+}
+
+func TestServerStatuses(t *testing.T) {
+	valid := func() map[string]string {
+		return map[string]string{
+			"id":        "42",
+			"nonce":     testNonce,
+			"otp":       testOneTimePassword,
+			"sl":        "secure",
+			"timeout":   "5",
+			"timestamp": "1",
+		}
+	}
+
+	cases := []struct {
+		Name     string
+		Verifier verifierFunc
+		SignWith []byte
+		Mutate   func(map[string]string)
+		Status   string
+		Signed   bool
+	}{
+		{Name: "ok", Verifier: acceptAll, SignWith: testClientKey, Status: "OK", Signed: true},
+		{Name: "unsigned request", Verifier: acceptAll, Status: "BAD_SIGNATURE"},
+		{Name: "bad signature", Verifier: acceptAll, SignWith: []byte("wrong"), Status: "BAD_SIGNATURE"},
+		{
+			Name: "unknown client", Verifier: acceptAll, SignWith: testClientKey,
+			Mutate: func(f map[string]string) { f["id"] = "43" },
+			Status: "NO_SUCH_CLIENT",
+		},
+		{
+			Name: "missing identifier", Verifier: acceptAll,
+			Mutate: func(f map[string]string) { delete(f, "id") },
+			Status: "MISSING_PARAMETER",
+		},
+		{
+			Name: "short nonce", Verifier: acceptAll, SignWith: testClientKey,
+			Mutate: func(f map[string]string) { f["nonce"] = "short" },
+			Status: "MISSING_PARAMETER", Signed: true,
+		},
+		{
+			Name: "invalid synchronization level", Verifier: acceptAll, SignWith: testClientKey,
+			Mutate: func(f map[string]string) { f["sl"] = "101" },
+			Status: "MISSING_PARAMETER", Signed: true,
+		},
+		{
+			Name: "short one time password", Verifier: acceptAll, SignWith: testClientKey,
+			Mutate: func(f map[string]string) { f["otp"] = "vvccccfiluij" },
+			Status: "BAD_OTP", Signed: true,
+		},
+		{
+			Name: "replayed one time password", SignWith: testClientKey,
+			Verifier: func(context.Context, yubikeyotp.Request) (*yubikeyotp.Result, error) {
+				return nil, yubikeyotp.ErrRequestReplayed
+			},
+			Status: "REPLAYED_OTP", Signed: true,
+		},
+		{
+			Name: "backend failure", SignWith: testClientKey,
+			Verifier: func(context.Context, yubikeyotp.Request) (*yubikeyotp.Result, error) {
+				return nil, errors.New("disk is on fire")
+			},
+			Status: "BACKEND_ERROR", Signed: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			server := newTestServer(t, tc.Verifier)
+			fields := valid()
+			if tc.Mutate != nil {
+				tc.Mutate(fields)
+			}
+			received := query(t, server, tc.SignWith, fields)
+			if received["status"] != tc.Status {
+				t.Fatalf("expected status %q, got %q", tc.Status, received["status"])
+			}
+			signature, signed := received["h"]
+			if signed != tc.Signed {
+				t.Fatalf("expected signed response %t, got %t", tc.Signed, signed)
+			}
+			if signed {
+				delete(received, "h")
+				if signature != base64.StdEncoding.EncodeToString(sign(testClientKey, received)) {
+					t.Fatal("response signature does not match")
+				}
+			}
+			if tc.Status == "OK" && received["sessioncounter"] != "7" {
+				t.Errorf("expected session counter, got %q", received["sessioncounter"])
+			}
+		})
+	}
+}
+
+func TestServerReplayedRequest(t *testing.T) {
+	server := newTestServer(t, verifierFunc(acceptAll))
+	fields := map[string]string{
+		"id":    "42",
+		"nonce": testNonce,
+		"otp":   testOneTimePassword,
+	}
+	if status := query(t, server, testClientKey, fields)["status"]; status != "OK" {
+		t.Fatalf("expected status OK, got %q", status)
+	}
+	if status := query(t, server, testClientKey, fields)["status"]; status != "REPLAYED_REQUEST" {
+		t.Fatalf("expected status REPLAYED_REQUEST, got %q", status)
+	}
+}
+
+func TestServerDoesNotEchoMalformedValues(t *testing.T) {
+	server := newTestServer(t, verifierFunc(acceptAll))
+	for key, fields := range map[string]map[string]string{
+		"otp":   {"id": "42", "nonce": testNonce, "otp": testOneTimePassword + "\r\ninjected=1"},
+		"nonce": {"id": "42", "nonce": testNonce + "\ninjected=1", "otp": testOneTimePassword},
+	} {
+		t.Run(key, func(t *testing.T) {
+			received := query(t, server, testClientKey, fields)
+			if _, ok := received["injected"]; ok {
+				t.Fatal("request injected a response field")
+			}
+			if received["status"] == "OK" {
+				t.Fatal("malformed request was accepted")
+			}
+			if _, ok := received[key]; ok {
+				t.Fatalf("malformed %s was echoed: %v", key, received)
+			}
+		})
+	}
+}
+
+// forbiddenClients knows the test client but does not let it verify one time passwords.
+type forbiddenClients struct{}
+
+func (forbiddenClients) LookupClientKey(_ context.Context, clientID uint) ([]byte, error) {
+	if clientID != testClientID {
+		return nil, yubikeyotp.ErrRequestClientDoesNotExist
+	}
+	return testClientKey, yubikeyotp.ErrRequestForbidden
+}
+
+func TestServerForbiddenClient(t *testing.T) {
+	handler, err := New(forbiddenClients{}, verifierFunc(acceptAll))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	fields := map[string]string{"id": "42", "nonce": testNonce, "otp": testOneTimePassword}
+	if received := query(t, server, []byte("wrong"), fields); received["status"] != "BAD_SIGNATURE" {
+		t.Fatalf("expected status BAD_SIGNATURE for a forged request, got %q", received["status"])
+	}
+	received := query(t, server, testClientKey, fields)
+	if received["status"] != "OPERATION_NOT_ALLOWED" {
+		t.Fatalf("expected status OPERATION_NOT_ALLOWED, got %q", received["status"])
+	}
+	signature, ok := received["h"]
+	if !ok {
+		t.Fatal("refusal is not signed")
+	}
+	delete(received, "h")
+	if signature != base64.StdEncoding.EncodeToString(sign(testClientKey, received)) {
+		t.Fatal("response signature does not match")
+	}
+
+	authenticator, err := yubikeyotp.New(yubikeyotp.WithEndpoints(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = authenticator.Authenticate(t.Context(), yubikeyotp.Request{
+		OneTimePassword: testOneTimePassword,
+		ClientID:        testClientID,
+		ClientSecret:    base64.StdEncoding.EncodeToString(testClientKey),
+	})
+	if !errors.Is(err, yubikeyotp.ErrRequestForbidden) {
+		t.Fatalf("expected error %v, got %v", yubikeyotp.ErrRequestForbidden, err)
+	}
+}
@@ -0,0 +1,63 @@
+package yubikeyotp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Tracer instruments validations, for example, with OpenTelemetry spans,
+// without adding dependencies to this package. Each start method returns
+// a context for the traced operation and a function that ends it.
+// See the otelyubikeyotp module for an implementation.
+type Tracer interface {
+	// StartVerification begins tracing a validation of a one time password
+	// issued by the YubiKey with the public identifier. The public
+	// identifier is empty, if the one time password could not be parsed.
+	StartVerification(ctx context.Context, publicID string) (context.Context, func(*Result, error))
+	// StartAttempt begins tracing a single request to an endpoint.
+	// The returned context is carried by the HTTP request,
+	// so that the transport can propagate trace context.
+	StartAttempt(ctx context.Context, endpoint string, number int) (context.Context, func(Attempt))
+}
+
+type noopTracer struct{}
+
+func (noopTracer) StartVerification(ctx context.Context, _ string) (context.Context, func(*Result, error)) {
+	return ctx, func(*Result, error) {}
+}
+
+func (noopTracer) StartAttempt(ctx context.Context, _ string, _ int) (context.Context, func(Attempt)) {
+	return ctx, func(Attempt) {}
+}
+
+// NewClientPool creates a pool of HTTP clients with the timeouts
+// used by default for [WithClientPool]. The clients share one transport,
+// which is passed through the wrap function, if it is not nil, to add
+// middleware, such as trace context propagation.
+func NewClientPool(wrap func(http.RoundTripper) http.RoundTripper) *sync.Pool {
+	var transport http.RoundTripper = &http.Transport{
+		MaxConnsPerHost:     20,
+		MaxIdleConnsPerHost: 5,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 60 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   3 * time.Second,
+		ResponseHeaderTimeout: 3 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if wrap != nil {
+		transport = wrap(transport)
+	}
+	return &sync.Pool{
+		New: func() any {
+			return &http.Client{
+				Timeout:   time.Second * 5,
+				Transport: transport,
+			}
+		},
+	}
+}
@@ -0,0 +1,328 @@
+package yubikeyotp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// verifyInSequence tries one endpoint at a time. Transient failures,
+// such as network errors, server errors, or BACKEND_ERROR status,
+// are retried on the next endpoint according to the [RetryPolicy].
+// Terminal answers, such as REPLAYED_OTP or BAD_OTP, are never retried.
+func (a *Authenticator) verifyInSequence(ctx context.Context, q signedQuery) (*Result, error) {
+	var delay time.Duration
+	endpoint := a.endpoints.Pick(nil, true)
+	tried := []string{endpoint}
+	failed := &AttemptsError{}
+
+	for number := 1; ; number++ {
+		result, attempt := a.exchange(ctx, endpoint, number, q)
+		if attempt.Err == nil {
+			return result, nil
+		}
+		failed.Attempts = append(failed.Attempts, attempt)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%w: %w", ctx.Err(), failed)
+		}
+		if !isRetryable(attempt.Err) {
+			return nil, attempt.Err
+		}
+
+		var retry bool
+		if delay, retry = a.retry.NextRetryDelay(number, delay); !retry {
+			return nil, failed
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", ctx.Err(), failed)
+		case <-time.After(delay):
+			previous := endpoint
+			endpoint = a.endpoints.Pick(tried, true)
+			tried = append(tried, endpoint)
+			a.metrics.CountRetry()
+			if endpoint != previous {
+				a.metrics.CountEndpointRotation()
+			}
+		}
+	}
+}
+
+// verifyInParallel sends the query to every endpoint at once
+// and takes the first answer that decides the outcome,
+// as recommended by Yubico for validation clients.
+// The remaining requests are cancelled as soon as the outcome is known.
+func (a *Authenticator) verifyInParallel(ctx context.Context, q signedQuery) (*Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type answer struct {
+		Result  *Result
+		Attempt Attempt
+	}
+	endpoints := a.endpoints.Available()
+	answers := make(chan answer, len(endpoints))
+	for i, endpoint := range endpoints {
+		go func() {
+			result, attempt := a.exchange(ctx, endpoint, i+1, q)
+			answers <- answer{Result: result, Attempt: attempt}
+		}()
+	}
+
+	failed := &AttemptsError{}
+	for range endpoints {
+		answer := <-answers
+		if answer.Attempt.Err == nil {
+			return answer.Result, nil
+		}
+		if isDecisive(answer.Attempt.Err) {
+			return nil, answer.Attempt.Err
+		}
+		failed.Attempts = append(failed.Attempts, answer.Attempt)
+	}
+	return nil, failed
+}
+
+// verifyWithHedging sends the query to the preferred endpoint and, if it
+// has not answered within the hedging delay, to the next one, and so on.
+// The first decisive answer wins and cancels the remaining requests.
+func (a *Authenticator) verifyWithHedging(ctx context.Context, q signedQuery) (*Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type answer struct {
+		Result  *Result
+		Attempt Attempt
+	}
+	answers := make(chan answer, a.endpoints.Len())
+	tried := make([]string, 0, cap(answers))
+	hedge := func() bool {
+		endpoint := a.endpoints.Pick(tried, true)
+		if endpoint == "" || slices.Contains(tried, endpoint) {
+			return false // every endpoint is already in flight
+		}
+		tried = append(tried, endpoint)
+		number := len(tried)
+		if number > 1 {
+			a.metrics.CountEndpointRotation()
+		}
+		go func() {
+			result, attempt := a.exchange(ctx, endpoint, number, q)
+			answers <- answer{Result: result, Attempt: attempt}
+		}()
+		return true
+	}
+
+	delay := a.hedgingDelay()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	hedge()
+	inFlight := 1
+	failed := &AttemptsError{}
+	for inFlight > 0 {
+		select {
+		case <-ctx.Done():
+			if len(failed.Attempts) > 0 {
+				return nil, fmt.Errorf("%w: %w", ctx.Err(), failed)
+			}
+			return nil, ctx.Err()
+		case <-timer.C:
+			if hedge() {
+				inFlight++
+				timer.Reset(delay)
+			}
+		case answer := <-answers:
+			inFlight--
+			if answer.Attempt.Err == nil {
+				return answer.Result, nil
+			}
+			if !isRetryable(answer.Attempt.Err) {
+				return nil, answer.Attempt.Err
+			}
+			failed.Attempts = append(failed.Attempts, answer.Attempt)
+			if hedge() {
+				inFlight++
+				timer.Reset(delay)
+			}
+		}
+	}
+	return nil, failed
+}
+
+// hedgingDelay returns the configured percentile of observed latency
+// or the fixed hedging delay, if there are not enough observations.
+func (a *Authenticator) hedgingDelay() time.Duration {
+	if a.hedging.Percentile > 0 {
+		if delay, ok := a.endpoints.LatencyPercentile(a.hedging.Percentile); ok {
+			return max(delay, time.Millisecond)
+		}
+	}
+	return a.hedging.Delay
+}
+
+// exchange sends the query to a single endpoint once, verifies the answer,
+// traces, logs, and measures the attempt, and reports the health of the endpoint unless
+// the context was cancelled.
+func (a *Authenticator) exchange(ctx context.Context, endpoint string, number int, q signedQuery) (*Result, Attempt) {
+	record := Attempt{Endpoint: endpoint, Number: number}
+	ctx, end := a.tracer.StartAttempt(ctx, endpoint, number)
+	start := time.Now()
+	result, err := a.exchangeOnce(ctx, q, &record)
+	record.Duration = time.Since(start)
+	record.Err = err
+	end(record)
+	a.logAttempt(ctx, record)
+	a.metrics.ObserveAttempt(endpoint, outcome(err), record.Duration)
+	if errors.Is(err, ErrResponseBadSignature) {
+		a.metrics.CountSignatureFailure(endpoint)
+	}
+	if ctx.Err() == nil {
+		failed := isEndpointFailure(err)
+		a.endpoints.Report(endpoint, record.Duration, failed)
+		if failed && a.hooks.OnEndpointFailure != nil {
+			a.hooks.OnEndpointFailure(ctx, q.Request, record)
+		}
+	}
+	return result, record
+}
+
+func (a *Authenticator) exchangeOnce(ctx context.Context, q signedQuery, record *Attempt) (*Result, error) {
+	client := a.clientPool.Get().(*http.Client)
+	defer a.clientPool.Put(client)
+
+	request, err := http.NewRequestWithContext(ctx, "GET", record.Endpoint+"?"+q.Query, nil)
+	if err != nil {
+		var urlError *url.Error
+		if errors.As(err, &urlError) {
+			// the query carries the one time password and the signature
+			err = urlError.Err
+		}
+		return nil, &endpointError{Endpoint: record.Endpoint, Err: err}
+	}
+	httpResponse, err := client.Do(request)
+	if err != nil {
+		var urlError *url.Error
+		if errors.As(err, &urlError) {
+			// the query carries the one time password and the signature
+			urlError.URL = record.Endpoint
+		}
+		return nil, fmt.Errorf("network client failed: %w", err)
+	}
+	defer httpResponse.Body.Close()
+
+	record.HTTPStatus = httpResponse.StatusCode
+	if httpResponse.StatusCode != http.StatusOK {
+		return nil, StatusCodeError(httpResponse.StatusCode)
+	}
+	response, err := parseResponse(httpResponse.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse response: %w", err)
+	}
+	record.Status = response.Status
+	if err = response.Verify(q.Secret); err != nil {
+		var requestError RequestError
+		if errors.As(err, &requestError) && echoesRequest(requestError) {
+			// a signed status is only trusted for the request it answers
+			if mismatch := response.VerifyBinding(q.Nonce, q.OneTimePassword); mismatch != nil {
+				return nil, fmt.Errorf("could not verify response: %w", mismatch)
+			}
+		}
+		return nil, fmt.Errorf("could not verify response: %w", err)
+	}
+	if err = response.VerifyBinding(q.Nonce, q.OneTimePassword); err != nil {
+		return nil, fmt.Errorf("could not verify response: %w", err)
+	}
+	if a.replayStore != nil && (response.SessionCounter == "" || response.SessionUse == "") {
+		// absent counters would read as zero and defeat the replay store
+		return nil, fmt.Errorf("could not read verified response: %w", ErrResponseMissingCounters)
+	}
+	result, err := newResult(response, record.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("could not read verified response: %w", err)
+	}
+	return result, nil
+}
+
+// endpointError reports a request to the endpoint that could not be built.
+type endpointError struct {
+	Endpoint string
+	Err      error
+}
+
+func (e *endpointError) Error() string {
+	return fmt.Sprintf("unable to build a request to endpoint %q: %v", e.Endpoint, e.Err)
+}
+
+func (e *endpointError) Unwrap() error {
+	return e.Err
+}
+
+// isDecisive returns true if the error settles the outcome of the validation,
+// so that answers from other endpoints need not be awaited.
+func isDecisive(err error) bool {
+	var requestError RequestError
+	if !errors.As(err, &requestError) {
+		return false
+	}
+	switch requestError {
+	case ErrRequestUnknownFailure, ErrRequestBackendError, ErrRequestDeadlineExceeded, ErrRequestReplayedRequest:
+		return false
+	default:
+		return true
+	}
+}
+
+// isRetryable returns true if the error is transient, so that
+// the request may succeed when sent again, possibly to a different endpoint.
+func isRetryable(err error) bool {
+	var (
+		requestError    RequestError
+		responseError   ResponseError
+		statusCodeError StatusCodeError
+		unverified      UnverifiedStatusError
+	)
+	switch {
+	case errors.As(err, new(*endpointError)):
+		// a request that cannot be built fails the same way every time
+		return false
+	case errors.As(err, &unverified):
+		// a forged failure status must not prevent validation by other endpoints
+		return true
+	case errors.As(err, &requestError):
+		return !isDecisive(requestError)
+	case errors.As(err, &responseError):
+		// signature failures may indicate tampering and are not retried
+		return false
+	case errors.As(err, &statusCodeError):
+		return statusCodeError >= 500 ||
+			statusCodeError == http.StatusTooManyRequests ||
+			statusCodeError == http.StatusRequestTimeout
+	case errors.Is(err, ErrRequestInvalidFormat):
+		return false
+	default:
+		// network failures and unparsable responses
+		return true
+	}
+}
+
+// isEndpointFailure returns true if the error indicates that the endpoint
+// is unhealthy. Signed protocol answers, such as REPLAYED_OTP or
+// REPLAYED_REQUEST, which synchronized servers send when a peer
+// has already validated the one time password, come from healthy endpoints.
+func isEndpointFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var (
+		requestError  RequestError
+		responseError ResponseError
+	)
+	if errors.As(err, &requestError) {
+		return false
+	}
+	return isRetryable(err) || errors.As(err, &responseError)
+}
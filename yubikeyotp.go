@@ -3,25 +3,42 @@ package yubikeyotp
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 )
 
+// Verifier validates one-time passwords. Both [Authenticator]
+// and [LocalValidator] satisfy it, so that callers can swap one for the other.
+type Verifier interface {
+	Verify(context.Context, Request) (*Result, error)
+}
+
+var (
+	_ Verifier = (*Authenticator)(nil)
+	_ Verifier = (*LocalValidator)(nil)
+)
+
 // Authenticator verifies one-time passwords using YubiKey API.
 // Create only with [New] constructor.
 type Authenticator struct {
-	clientPool             *sync.Pool
-	nonceGenerator         NonceGenerator
-	retryLimit             int
-	retryBackoffDelay      time.Duration
-	retryBackoffMultiplier time.Duration
-	syncFactor             string
-	syncTimeLimit          string
-
-	mu                   sync.Mutex
-	currentEndpointIndex int
-	endpoints            []string
+	clientPool     *sync.Pool
+	nonceGenerator NonceGenerator
+	retry          RetryPolicy
+	syncFactor     string
+	syncTimeLimit  string
+	fanOut         bool
+	hedging        *Hedging
+	logger         *slog.Logger
+	tracer         Tracer
+	metrics        Metrics
+	hooks          Hooks
+	registry       Registry
+	replayStore    ReplayStore
+	credentials    CredentialProvider
+	endpoints      *endpointPool
 }
 
 // New creates a new YubiKey one-time password [Authenticator].
@@ -35,54 +52,134 @@ func New(withOptions ...Option) (_ *Authenticator, err error) {
 		defaultEndpoints,
 		defaultRetryWithBackOff,
 		defaultClientPool,
+		defaultCircuitBreaker,
+		defaultLogger,
+		defaultTracer,
+		defaultMetrics,
 	) {
 		if err = option(&o); err != nil {
 			return nil, fmt.Errorf("unable to initialize Yubi Key authenticator: %w", err)
 		}
 	}
 
+	hooks := Hooks{}
+	if o.Hooks != nil {
+		hooks = *o.Hooks
+	}
 	return &Authenticator{
-		clientPool:             o.ClientPool,
-		nonceGenerator:         o.NonceGenerator,
-		retryLimit:             int(o.Retry.AttemptLimit),
-		retryBackoffDelay:      o.Retry.AttemptDelay,
-		retryBackoffMultiplier: time.Duration(o.Retry.AttemptDelayMultiplier),
-		syncFactor:             fmt.Sprintf("%d", *o.SynchronizationFactor),
-		syncTimeLimit:          fmt.Sprintf("%d", *o.SynchronizationTimeLimit),
-
-		mu:        sync.Mutex{},
-		endpoints: o.Endpoints,
+		clientPool:     o.ClientPool,
+		nonceGenerator: o.NonceGenerator,
+		retry:          o.Retry,
+		syncFactor:     fmt.Sprintf("%d", *o.SynchronizationFactor),
+		syncTimeLimit:  fmt.Sprintf("%d", *o.SynchronizationTimeLimit),
+		fanOut:         o.FanOut,
+		hedging:        o.Hedging,
+		logger:         o.Logger,
+		tracer:         o.Tracer,
+		metrics:        o.Metrics,
+		hooks:          hooks,
+		registry:       o.Registry,
+		replayStore:    o.ReplayStore,
+		credentials:    o.Credentials,
+		endpoints: newEndpointPool(
+			o.Endpoints,
+			o.CircuitBreaker.FailureThreshold,
+			o.CircuitBreaker.CoolDown,
+		),
 	}, nil
 }
 
 // Authenticate verifies a one-time password using YubiKey API.
 func (a *Authenticator) Authenticate(ctx context.Context, r Request) error {
-	secret, err := base64.StdEncoding.DecodeString(r.ClientSecret)
+	_, err := a.Verify(ctx, r)
+	return err
+}
+
+// Verify validates a one-time password using YubiKey API
+// and returns the verified details of the validation.
+func (a *Authenticator) Verify(ctx context.Context, r Request) (result *Result, err error) {
+	started := time.Now()
+	otp, err := ParseOTP(r.OneTimePassword)
 	if err != nil {
-		return fmt.Errorf("invalid client secret: %w", err)
+		ctx, end := a.tracer.StartVerification(ctx, "")
+		end(nil, err)
+		a.logOutcome(ctx, "", started, nil, err)
+		a.metrics.ObserveVerification(outcome(err), time.Since(started))
+		a.callOutcomeHooks(ctx, r.redacted(), nil, err)
+		return nil, err
+	}
+	ctx, end := a.tracer.StartVerification(ctx, otp.PublicID)
+	defer func() { end(result, err) }()
+	r.OneTimePassword = otp.String() // validation servers expect modhex
+	result, err = a.verify(ctx, r)
+	a.logOutcome(ctx, otp.PublicID, started, result, err)
+	a.metrics.ObserveVerification(outcome(err), time.Since(started))
+	a.callOutcomeHooks(ctx, r.redacted(), result, err)
+	return result, err
+}
+
+func (a *Authenticator) verify(ctx context.Context, r Request) (*Result, error) {
+	credentials, err := a.requestCredentials(ctx, r)
+	if err != nil {
+		return nil, err
 	}
 	nonce, err := a.nonceGenerator.GenerateNonce()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	httpResponse, err := a.sendQuery(ctx, a.buildSignedRequestQuery(
-		r.OneTimePassword,
-		r.ClientID,
-		secret,
-		nonce,
-	))
-	if err != nil {
-		return fmt.Errorf("network client failed: %w", err)
+	q := signedQuery{
+		Query: a.buildSignedRequestQuery(
+			r.OneTimePassword,
+			credentials.ClientID,
+			credentials.Secret,
+			nonce,
+		),
+		Secret:          credentials.Secret,
+		Nonce:           nonce,
+		OneTimePassword: r.OneTimePassword,
+		Request:         r.redacted(),
+	}
+	var result *Result
+	switch {
+	case a.fanOut:
+		result, err = a.verifyInParallel(ctx, q)
+	case a.hedging != nil:
+		result, err = a.verifyWithHedging(ctx, q)
+	default:
+		result, err = a.verifyInSequence(ctx, q)
 	}
-	defer httpResponse.Body.Close()
-	response, err := parseResponse(httpResponse.Body)
 	if err != nil {
-		return fmt.Errorf("could not parse response: %w", err)
+		return nil, err
 	}
 
-	if err = response.Verify(secret); err != nil {
-		return fmt.Errorf("could not verify response: %w", err)
+	if a.replayStore != nil {
+		if err = a.replayStore.Advance(ctx, result.PublicID, ReplayCounter{
+			Session: result.SessionCounter,
+			Use:     result.SessionUse,
+		}); err != nil {
+			return nil, fmt.Errorf("local replay guard rejected the one time password: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// requestCredentials takes credentials from the request if they are present
+// or looks them up by tenant using the configured [CredentialProvider].
+func (a *Authenticator) requestCredentials(ctx context.Context, r Request) (Credentials, error) {
+	if r.ClientSecret != "" {
+		secret, err := base64.StdEncoding.DecodeString(r.ClientSecret)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("invalid client secret: %w", err)
+		}
+		return Credentials{ClientID: r.ClientID, Secret: secret}, nil
+	}
+	if a.credentials == nil {
+		return Credentials{}, errors.New("request has no client secret and credential provider is not configured")
+	}
+	credentials, err := a.credentials.Credentials(ctx, r.Tenant)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("unable to look up client credentials: %w", err)
 	}
-	return nil
+	return credentials, nil
 }
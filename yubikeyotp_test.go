@@ -1,10 +1,19 @@
 package yubikeyotp
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 const (
@@ -54,3 +63,357 @@ func TestAuthentication(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+const (
+	stubClientID        = 87
+	stubOneTimePassword = "vvccccfiluijkvkjghvtjcjcclfbvjhclrrkbetebghv"
+)
+
+var stubClientSecret = []byte("stub client secret")
+
+// newStubServer starts a validation server that answers every request
+// with fields produced by the respond function signed with [stubClientSecret].
+func newStubServer(t *testing.T, respond func(query url.Values) map[string]string) *Authenticator {
+	t.Helper()
+	authenticator, err := New(WithEndpoints(startStubServer(t, respond)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authenticator
+}
+
+// startStubServer returns the endpoint of a validation server that answers every request
+// with fields produced by the respond function signed with [stubClientSecret].
+func startStubServer(t *testing.T, respond func(query url.Values) map[string]string) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, signTestResponse(stubClientSecret, respond(r.URL.Query())))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func stubRequest() Request {
+	return Request{
+		OneTimePassword: stubOneTimePassword,
+		ClientID:        stubClientID,
+		ClientSecret:    base64.StdEncoding.EncodeToString(stubClientSecret),
+	}
+}
+
+func echoFields(query url.Values) map[string]string {
+	return map[string]string{
+		"nonce":          query.Get("nonce"),
+		"otp":            query.Get("otp"),
+		"sessioncounter": "19",
+		"sessionuse":     "17",
+		"sl":             "100",
+		"status":         "OK",
+		"t":              "2024-05-01T10:00:00Z0123",
+		"timestamp":      "8256",
+	}
+}
+
+func TestAuthenticationAgainstStubServer(t *testing.T) {
+	authenticator := newStubServer(t, echoFields)
+	result, err := authenticator.Verify(t.Context(), stubRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.PublicID != "vvccccfiluij" || result.SessionCounter != 19 || result.SessionUse != 17 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestResponseBindingToRequest(t *testing.T) {
+	cases := map[string]struct {
+		Mutate   func(map[string]string)
+		Expected error
+	}{
+		"nonce mismatch": {
+			Mutate: func(fields map[string]string) {
+				fields["nonce"] = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+			},
+			Expected: ErrResponseNonceMismatch,
+		},
+		"empty nonce": {
+			Mutate: func(fields map[string]string) {
+				fields["nonce"] = ""
+			},
+			Expected: ErrResponseNonceMismatch,
+		},
+		"missing nonce": {
+			Mutate: func(fields map[string]string) {
+				delete(fields, "nonce")
+			},
+			Expected: ErrResponseNonceMismatch,
+		},
+		"one time password mismatch": {
+			Mutate: func(fields map[string]string) {
+				fields["otp"] = "vvccccfiluijhbhkldkjrfkfcujcrhgrkbfhenceknbd"
+			},
+			Expected: ErrResponseOneTimePasswordMismatch,
+		},
+		"replayed status for another nonce": {
+			Mutate: func(fields map[string]string) {
+				fields["status"] = "REPLAYED_OTP"
+				fields["nonce"] = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+			},
+			Expected: ErrResponseNonceMismatch,
+		},
+		"bad one time password status for another one time password": {
+			Mutate: func(fields map[string]string) {
+				fields["status"] = "BAD_OTP"
+				fields["otp"] = "vvccccfiluijhbhkldkjrfkfcujcrhgrkbfhenceknbd"
+			},
+			Expected: ErrResponseOneTimePasswordMismatch,
+		},
+		"operation not allowed status without nonce": {
+			Mutate: func(fields map[string]string) {
+				fields["status"] = "OPERATION_NOT_ALLOWED"
+				delete(fields, "nonce")
+			},
+			Expected: ErrResponseNonceMismatch,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			authenticator := newStubServer(t, func(query url.Values) map[string]string {
+				fields := echoFields(query)
+				tc.Mutate(fields)
+				return fields
+			})
+			err := authenticator.Authenticate(t.Context(), stubRequest())
+			if !errors.Is(err, tc.Expected) {
+				t.Fatalf("expected error %v, got %v", tc.Expected, err)
+			}
+			var requestError RequestError
+			if errors.As(err, &requestError) {
+				t.Fatalf("status of a response to another request was trusted: %v", err)
+			}
+		})
+	}
+}
+
+func withStatus(status string) func(url.Values) map[string]string {
+	return func(query url.Values) map[string]string {
+		fields := echoFields(query)
+		fields["status"] = status
+		return fields
+	}
+}
+
+func TestFanOutVerification(t *testing.T) {
+	stalled := func(t *testing.T) string {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second * 5):
+			}
+		}))
+		t.Cleanup(server.Close)
+		return server.URL
+	}
+
+	cases := map[string]struct {
+		Endpoints func(t *testing.T) []string
+		Expected  error
+	}{
+		"first valid answer wins over a stalled endpoint": {
+			Endpoints: func(t *testing.T) []string {
+				return []string{stalled(t), startStubServer(t, echoFields)}
+			},
+		},
+		"backend error is ignored while others answer": {
+			Endpoints: func(t *testing.T) []string {
+				return []string{
+					startStubServer(t, withStatus("BACKEND_ERROR")),
+					startStubServer(t, echoFields),
+				}
+			},
+		},
+		"replayed one time password is rejected": {
+			Endpoints: func(t *testing.T) []string {
+				return []string{stalled(t), startStubServer(t, withStatus("REPLAYED_OTP"))}
+			},
+			Expected: ErrRequestReplayed,
+		},
+		"all endpoints fail": {
+			Endpoints: func(t *testing.T) []string {
+				return []string{
+					startStubServer(t, withStatus("BACKEND_ERROR")),
+					startStubServer(t, withStatus("NOT_ENOUGH_ANSWERS")),
+				}
+			},
+			Expected: ErrRequestBackendError,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			authenticator, err := New(WithEndpoints(tc.Endpoints(t)...), WithFanOut())
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(t.Context(), time.Second*2)
+			defer cancel()
+			err = authenticator.Authenticate(ctx, stubRequest())
+			if tc.Expected == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if !errors.Is(err, tc.Expected) {
+				t.Fatalf("expected error %v, got %v", tc.Expected, err)
+			}
+		})
+	}
+}
+
+func TestSequentialRetryClassification(t *testing.T) {
+	respondWith := func(status int, body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+		}
+	}
+
+	cases := []struct {
+		Name     string
+		First    func(t *testing.T) string
+		Expected error
+		Retried  bool
+	}{
+		{
+			Name: "server error is retried",
+			First: func(t *testing.T) string {
+				server := httptest.NewServer(respondWith(http.StatusBadGateway, "bad gateway"))
+				t.Cleanup(server.Close)
+				return server.URL
+			},
+			Retried: true,
+		},
+		{
+			Name: "HTML error page is retried",
+			First: func(t *testing.T) string {
+				server := httptest.NewServer(respondWith(http.StatusOK, "<html><body>maintenance</body></html>"))
+				t.Cleanup(server.Close)
+				return server.URL
+			},
+			Retried: true,
+		},
+		{
+			Name:    "backend error is retried",
+			First:   func(t *testing.T) string { return startStubServer(t, withStatus("BACKEND_ERROR")) },
+			Retried: true,
+		},
+		{
+			Name:    "not enough answers is retried",
+			First:   func(t *testing.T) string { return startStubServer(t, withStatus("NOT_ENOUGH_ANSWERS")) },
+			Retried: true,
+		},
+		{
+			Name: "unsigned bad signature is retried",
+			First: func(t *testing.T) string {
+				server := httptest.NewServer(respondWith(http.StatusOK, "status=BAD_SIGNATURE\r\nt=2007-01-09T14:21:49Z0493\r\n"))
+				t.Cleanup(server.Close)
+				return server.URL
+			},
+			Retried: true,
+		},
+		{
+			Name: "client error is not retried",
+			First: func(t *testing.T) string {
+				server := httptest.NewServer(respondWith(http.StatusNotFound, "not found"))
+				t.Cleanup(server.Close)
+				return server.URL
+			},
+			Expected: StatusCodeError(http.StatusNotFound),
+		},
+		{
+			Name:     "replayed one time password is not retried",
+			First:    func(t *testing.T) string { return startStubServer(t, withStatus("REPLAYED_OTP")) },
+			Expected: ErrRequestReplayed,
+		},
+		{
+			Name:     "bad one time password is not retried",
+			First:    func(t *testing.T) string { return startStubServer(t, withStatus("BAD_OTP")) },
+			Expected: ErrRequestInvalidFormat,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			retried := atomic.Bool{}
+			second := startStubServer(t, func(query url.Values) map[string]string {
+				retried.Store(true)
+				return echoFields(query)
+			})
+			authenticator, err := New(
+				WithEndpoints(tc.First(t), second),
+				WithRetryPolicy(RetryWithBackOff{
+					AttemptLimit:           2,
+					AttemptDelay:           time.Millisecond * 31,
+					AttemptDelayLimit:      time.Millisecond * 31,
+					AttemptDelayMultiplier: 2,
+				}),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = authenticator.Authenticate(t.Context(), stubRequest())
+			if tc.Expected == nil && err != nil {
+				t.Fatal(err)
+			}
+			if tc.Expected != nil && !errors.Is(err, tc.Expected) {
+				t.Fatalf("expected error %v, got %v", tc.Expected, err)
+			}
+			if retried.Load() != tc.Retried {
+				t.Fatalf("expected retry %t, got %t", tc.Retried, retried.Load())
+			}
+		})
+	}
+}
+
+func TestAttemptsErrorCollectsEveryAttempt(t *testing.T) {
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(unavailable.Close)
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	authenticator, err := New(
+		WithEndpoints(unavailable.URL, unreachable.URL),
+		WithRetryPolicy(RetryWithBackOff{
+			AttemptLimit:           2,
+			AttemptDelay:           time.Millisecond * 31,
+			AttemptDelayLimit:      time.Millisecond * 31,
+			AttemptDelayMultiplier: 2,
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = authenticator.Authenticate(t.Context(), stubRequest())
+
+	var attemptsError *AttemptsError
+	if !errors.As(err, &attemptsError) {
+		t.Fatalf("expected attempts error, got %v", err)
+	}
+	if len(attemptsError.Attempts) != 2 {
+		t.Fatalf("expected two attempts, got %d", len(attemptsError.Attempts))
+	}
+	if first := attemptsError.Attempts[0]; first.Endpoint != unavailable.URL || first.HTTPStatus != http.StatusServiceUnavailable {
+		t.Errorf("unexpected first attempt: %+v", first)
+	}
+	if second := attemptsError.Attempts[1]; second.Endpoint != unreachable.URL || second.Err == nil {
+		t.Errorf("unexpected second attempt: %+v", second)
+	}
+	if !errors.Is(err, StatusCodeError(http.StatusServiceUnavailable)) {
+		t.Errorf("earlier attempt error is lost: %v", err)
+	}
+	if strings.Contains(err.Error(), stubOneTimePassword) {
+		t.Errorf("error message leaks the one time password: %v", err)
+	}
+}
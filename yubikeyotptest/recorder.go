@@ -0,0 +1,146 @@
+package yubikeyotptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/dkotik/yubikeyotp"
+)
+
+// Exchange is a recorded request to a validation endpoint and its answer.
+type Exchange struct {
+	// Query is the signed request query.
+	Query      string `json:"query"`
+	StatusCode int    `json:"status_code"`
+	// Body is the raw response body.
+	Body string `json:"body"`
+}
+
+// Transport records validation exchanges to a golden file or serves
+// them back without network access. Fix the nonce with [FixedNonce]
+// and [yubikeyotp.WithNonceGenerator], so that replayed queries match
+// the recorded ones. Create only with [Record] or [Replay] constructors.
+type Transport struct {
+	path      string
+	base      http.RoundTripper
+	mu        sync.Mutex
+	exchanges []Exchange
+	replayed  []bool
+}
+
+// Record creates a [Transport] that passes requests to the base
+// transport and remembers them until [Transport.Save].
+// Nil base uses [http.DefaultTransport].
+func Record(path string, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{path: path, base: base}
+}
+
+// Replay creates a [Transport] that answers requests from the golden file.
+// Each recorded exchange is served once in recorded order.
+func Replay(path string) (*Transport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read recording: %w", err)
+	}
+	t := &Transport{path: path}
+	if err = json.Unmarshal(data, &t.exchanges); err != nil {
+		return nil, fmt.Errorf("unable to decode recording %q: %w", path, err)
+	}
+	t.replayed = make([]bool, len(t.exchanges))
+	return t, nil
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.base == nil {
+		return t.replay(r)
+	}
+	response, err := t.base.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	t.exchanges = append(t.exchanges, Exchange{
+		Query:      r.URL.RawQuery,
+		StatusCode: response.StatusCode,
+		Body:       string(body),
+	})
+	t.mu.Unlock()
+	response.Body = io.NopCloser(strings.NewReader(string(body)))
+	return response, nil
+}
+
+func (t *Transport) replay(r *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, exchange := range t.exchanges {
+		if t.replayed[i] || exchange.Query != r.URL.RawQuery {
+			continue
+		}
+		t.replayed[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", exchange.StatusCode, http.StatusText(exchange.StatusCode)),
+			StatusCode:    exchange.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+			Body:          io.NopCloser(strings.NewReader(exchange.Body)),
+			ContentLength: int64(len(exchange.Body)),
+			Request:       r,
+		}, nil
+	}
+	return nil, fmt.Errorf("recording %q has no exchange left for the request", t.path)
+}
+
+// Exchanges returns recorded or loaded exchanges.
+func (t *Transport) Exchanges() []Exchange {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Exchange(nil), t.exchanges...)
+}
+
+// Save writes recorded exchanges to the golden file.
+func (t *Transport) Save() error {
+	if t.base == nil {
+		return errors.New("replaying transport cannot save recordings")
+	}
+	b := bytes.Buffer{}
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	t.mu.Lock()
+	err := encoder.Encode(t.exchanges)
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(t.path, b.Bytes(), 0o644)
+}
+
+// FixedNonce returns a generator that repeats the nonce, which must
+// contain 40 alphanumeric characters. Use it only for recordings.
+func FixedNonce(nonce string) yubikeyotp.NonceGenerator {
+	return yubikeyotp.NonceGeneratorFunc(func() (n yubikeyotp.Nonce, err error) {
+		if len(nonce) != len(n) {
+			return n, fmt.Errorf("nonce must contain %d characters", len(n))
+		}
+		copy(n[:], nonce)
+		return n, nil
+	})
+}
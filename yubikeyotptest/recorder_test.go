@@ -0,0 +1,108 @@
+package yubikeyotptest
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dkotik/yubikeyotp"
+)
+
+var update = flag.Bool("update", false, "record golden files in ../testdata/recordings again")
+
+const (
+	recordingOneTimePassword = "vvcccccccccccbdefghijklnrtuvcbdefghijklnrtuv"
+	recordingNonce           = "0123456789abcdefghijABCDEFGHIJ0123456789"
+)
+
+// recordingSecret signs recordings made with the fake [Server].
+// The core package uses it to verify the golden files.
+var recordingSecret = []byte("recording secret")
+
+func newRecordingAuthenticator(t *testing.T, transport http.RoundTripper, endpoint string) *yubikeyotp.Authenticator {
+	t.Helper()
+	authenticator, err := yubikeyotp.New(
+		yubikeyotp.WithEndpoints(endpoint),
+		yubikeyotp.WithNonceGenerator(FixedNonce(recordingNonce)),
+		yubikeyotp.WithClientPool(yubikeyotp.NewClientPool(func(http.RoundTripper) http.RoundTripper {
+			return transport
+		})),
+		yubikeyotp.WithRetryPolicy(yubikeyotp.RetryWithBackOff{
+			AttemptLimit:           1,
+			AttemptDelay:           time.Millisecond * 31,
+			AttemptDelayLimit:      time.Millisecond * 31,
+			AttemptDelayMultiplier: 2,
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authenticator
+}
+
+func TestRecordings(t *testing.T) {
+	cases := []struct {
+		Name   string
+		Answer Answer
+		Check  func(error) bool
+	}{
+		{
+			Name:   "ok",
+			Answer: Answer{SessionCounter: 19, SessionUse: 3, Timestamp: 9127},
+			Check:  func(err error) bool { return err == nil },
+		},
+		{
+			Name:   "replayed_otp",
+			Answer: Status("REPLAYED_OTP"),
+			Check:  func(err error) bool { return errors.Is(err, yubikeyotp.ErrRequestReplayed) },
+		},
+		{
+			Name:   "backend_error",
+			Answer: Status("BACKEND_ERROR"),
+			Check:  func(err error) bool { return errors.Is(err, yubikeyotp.ErrRequestBackendError) },
+		},
+		{
+			Name:   "bad_signature",
+			Answer: BadSignature(),
+			Check:  func(err error) bool { return errors.Is(err, yubikeyotp.ErrResponseBadSignature) },
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			path := filepath.Join("..", "testdata", "recordings", c.Name+".json")
+			if *update {
+				server := NewServer(recordingSecret)
+				t.Cleanup(server.Close)
+				server.Script(recordingOneTimePassword, c.Answer)
+				recorder := Record(path, nil)
+				if err := newRecordingAuthenticator(t, recorder, server.URL).Authenticate(
+					t.Context(), server.Request(recordingOneTimePassword),
+				); !c.Check(err) {
+					t.Fatalf("unexpected error while recording: %v", err)
+				}
+				if err := recorder.Save(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			replay, err := Replay(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			server := Server{ClientID: 1, Secret: recordingSecret}
+			err = newRecordingAuthenticator(t, replay, "https://recording.invalid/wsapi/2.0/verify").Authenticate(
+				t.Context(), server.Request(recordingOneTimePassword),
+			)
+			if !c.Check(err) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err = replay.RoundTrip(&http.Request{URL: &url.URL{RawQuery: replay.Exchanges()[0].Query}}); err == nil {
+				t.Fatal("recorded exchange was served twice")
+			}
+		})
+	}
+}
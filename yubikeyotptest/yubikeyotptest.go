@@ -0,0 +1,285 @@
+/*
+Package yubikeyotptest provides an in-process fake of the Yubico
+validation server for testing login flows offline against
+the real [yubikeyotp.Authenticator].
+
+	server := yubikeyotptest.NewServer([]byte("secret"))
+	defer server.Close()
+	otp := yubikeyotptest.NewOneTimePassword("vvcccccccccc")
+	server.Script(otp, yubikeyotptest.Slow(time.Second, yubikeyotptest.OK()))
+
+	authenticator, err := yubikeyotp.New(yubikeyotp.WithEndpoints(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = authenticator.Authenticate(ctx, server.Request(otp))
+*/
+package yubikeyotptest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/dkotik/yubikeyotp"
+	"github.com/dkotik/yubikeyotp/server"
+)
+
+// Answer scripts the response of a [Server] to a one time password.
+type Answer struct {
+	// Status is the protocol status. Empty status means "OK".
+	Status string
+	// Delay postpones the response unless the client gives up first.
+	Delay time.Duration
+	// HTTPStatus other than zero replaces the response with an HTTP error.
+	HTTPStatus int
+	// Body other than empty replaces the response as is.
+	Body string
+	// BadSignature signs the response with a wrong key.
+	BadSignature bool
+	// SessionCounter, SessionUse, and Timestamp are reported
+	// with "OK" status, if the client asks for them.
+	SessionCounter uint16
+	SessionUse     uint8
+	Timestamp      uint32
+}
+
+// OK accepts the one time password.
+func OK() Answer {
+	return Answer{}
+}
+
+// Status answers with the protocol status, such as "REPLAYED_OTP" or "BACKEND_ERROR".
+// Statuses unknown to [yubikeyotp.RequestError] are reported as "BACKEND_ERROR".
+func Status(status string) Answer {
+	return Answer{Status: status}
+}
+
+// BadSignature accepts the one time password with a response
+// that fails signature verification, as if it was tampered with.
+func BadSignature() Answer {
+	return Answer{BadSignature: true}
+}
+
+// ServerError answers with HTTP status 500.
+func ServerError() Answer {
+	return Answer{HTTPStatus: http.StatusInternalServerError}
+}
+
+// Garbage answers with a body that is not a validation protocol response.
+func Garbage() Answer {
+	return Answer{Body: "<html><body>502 Bad Gateway</body></html>"}
+}
+
+// Slow delays the answer.
+func Slow(delay time.Duration, a Answer) Answer {
+	a.Delay = delay
+	return a
+}
+
+// Server speaks the validation protocol version 2.0 through [server.Handler]
+// and signs responses with the client secret. Like a real validation server,
+// it rejects requests with a wrong client identifier or signature and
+// answers "REPLAYED_OTP" to one time passwords it accepted before.
+// Create only with [NewServer] constructor.
+type Server struct {
+	// URL is the validation endpoint for [yubikeyotp.WithEndpoints].
+	URL      string
+	ClientID uint
+	Secret   []byte
+
+	httpServer *httptest.Server
+	handler    *server.Handler
+
+	mu       sync.Mutex
+	answers  map[string]Answer
+	fallback Answer
+	accepted map[string]struct{}
+	requests int
+}
+
+// NewServer starts a fake validation server for client 1 with the secret.
+// Call [Server.Close] when done. One time passwords without
+// a scripted [Answer] are accepted.
+func NewServer(secret []byte) *Server {
+	s := &Server{
+		ClientID: 1,
+		Secret:   secret,
+		answers:  make(map[string]Answer),
+		accepted: make(map[string]struct{}),
+	}
+	handler, err := server.New(clientStore{s}, scriptedVerifier{s})
+	if err != nil {
+		panic(err) // both arguments are set
+	}
+	s.handler = handler
+	s.httpServer = httptest.NewServer(s)
+	s.URL = s.httpServer.URL + "/wsapi/2.0/verify"
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.httpServer.Close()
+}
+
+// Script sets the answer to the one time password.
+func (s *Server) Script(otp string, a Answer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.answers[otp] = a
+}
+
+// SetDefault sets the answer to one time passwords that were not scripted.
+func (s *Server) SetDefault(a Answer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallback = a
+}
+
+// Requests returns the number of requests received.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Request prepares a validation request with the server credentials.
+func (s *Server) Request(otp string) yubikeyotp.Request {
+	return yubikeyotp.Request{
+		OneTimePassword: otp,
+		ClientID:        s.ClientID,
+		ClientSecret:    base64.StdEncoding.EncodeToString(s.Secret),
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	s.mu.Unlock()
+	answer := s.answer(r.URL.Query().Get("otp"))
+
+	if answer.Delay > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(answer.Delay):
+		}
+	}
+	if answer.HTTPStatus != 0 {
+		http.Error(w, http.StatusText(answer.HTTPStatus), answer.HTTPStatus)
+		return
+	}
+	if answer.Body != "" {
+		_, _ = w.Write([]byte(answer.Body))
+		return
+	}
+	if !answer.BadSignature {
+		s.handler.ServeHTTP(w, r)
+		return
+	}
+
+	recorder := httptest.NewRecorder()
+	s.handler.ServeHTTP(recorder, r)
+	for key, values := range recorder.Header() {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(recorder.Code)
+	_, _ = w.Write(tamperWithSignature(recorder.Body.Bytes()))
+}
+
+// answer returns the scripted answer to the one time password.
+func (s *Server) answer(otp string) Answer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	answer, ok := s.answers[otp]
+	if !ok {
+		answer = s.fallback
+	}
+	return answer
+}
+
+// tamperWithSignature flips a bit of the response signature.
+func tamperWithSignature(body []byte) []byte {
+	b := bytes.Buffer{}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if encoded, ok := bytes.CutPrefix(line, []byte("h=")); ok {
+			if signature, err := base64.StdEncoding.DecodeString(string(encoded)); err == nil && len(signature) > 0 {
+				signature[0] ^= 1
+				line = []byte("h=" + base64.StdEncoding.EncodeToString(signature))
+			}
+		}
+		_, _ = b.Write(line)
+		_, _ = b.WriteString("\r\n")
+	}
+	return b.Bytes()
+}
+
+// clientStore knows the only client of the [Server].
+type clientStore struct {
+	*Server
+}
+
+func (c clientStore) LookupClientKey(_ context.Context, clientID uint) ([]byte, error) {
+	if clientID != c.ClientID {
+		return nil, yubikeyotp.ErrRequestClientDoesNotExist
+	}
+	return c.Secret, nil
+}
+
+// scriptedVerifier decides the validation outcome from the scripted [Answer].
+type scriptedVerifier struct {
+	*Server
+}
+
+func (v scriptedVerifier) Verify(_ context.Context, r yubikeyotp.Request) (*yubikeyotp.Result, error) {
+	answer := v.answer(r.OneTimePassword)
+	if answer.Status != "" && answer.Status != "OK" {
+		if err, ok := statusErrors[answer.Status]; ok {
+			return nil, err
+		}
+		return nil, yubikeyotp.ErrRequestBackendError
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, ok := v.accepted[r.OneTimePassword]; ok {
+		return nil, yubikeyotp.ErrRequestReplayed
+	}
+	v.accepted[r.OneTimePassword] = struct{}{}
+	return &yubikeyotp.Result{
+		SessionCounter:      uint(answer.SessionCounter),
+		SessionUse:          uint(answer.SessionUse),
+		ActivationTimestamp: uint(answer.Timestamp),
+	}, nil
+}
+
+// statusErrors maps protocol failure statuses to errors
+// that [server.Handler] reports with the same statuses.
+var statusErrors = map[string]error{
+	"BAD_OTP":               yubikeyotp.ErrRequestInvalidFormat,
+	"REPLAYED_OTP":          yubikeyotp.ErrRequestReplayed,
+	"BAD_SIGNATURE":         yubikeyotp.ErrRequestBadSignature,
+	"MISSING_PARAMETER":     yubikeyotp.ErrRequestMissingParameter,
+	"NO_SUCH_CLIENT":        yubikeyotp.ErrRequestClientDoesNotExist,
+	"OPERATION_NOT_ALLOWED": yubikeyotp.ErrRequestForbidden,
+	"NOT_ENOUGH_ANSWERS":    yubikeyotp.ErrRequestDeadlineExceeded,
+	"BACKEND_ERROR":         yubikeyotp.ErrRequestBackendError,
+	"REPLAYED_REQUEST":      yubikeyotp.ErrRequestReplayedRequest,
+}
+
+// NewOneTimePassword returns a random one time password
+// in valid format for the YubiKey public identifier.
+func NewOneTimePassword(publicID string) string {
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+	return publicID + yubikeyotp.EncodeModhex(token)
+}
@@ -0,0 +1,112 @@
+package yubikeyotptest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dkotik/yubikeyotp"
+)
+
+func TestServerAnswers(t *testing.T) {
+	server := NewServer([]byte("test secret"))
+	t.Cleanup(server.Close)
+	authenticator, err := yubikeyotp.New(
+		yubikeyotp.WithEndpoints(server.URL),
+		yubikeyotp.WithRetryPolicy(yubikeyotp.RetryWithBackOff{
+			AttemptLimit:           1,
+			AttemptDelay:           time.Millisecond * 31,
+			AttemptDelayLimit:      time.Millisecond * 31,
+			AttemptDelayMultiplier: 2,
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		Name   string
+		Answer Answer
+		Check  func(error) bool
+	}{
+		{Name: "ok", Answer: OK(), Check: func(err error) bool { return err == nil }},
+		{
+			Name:   "replayed",
+			Answer: Status("REPLAYED_OTP"),
+			Check:  func(err error) bool { return errors.Is(err, yubikeyotp.ErrRequestReplayed) },
+		},
+		{
+			Name:   "bad signature",
+			Answer: BadSignature(),
+			Check:  func(err error) bool { return errors.Is(err, yubikeyotp.ErrResponseBadSignature) },
+		},
+		{
+			Name:   "server error",
+			Answer: ServerError(),
+			Check: func(err error) bool {
+				return errors.Is(err, yubikeyotp.StatusCodeError(500))
+			},
+		},
+		{
+			Name:   "garbage",
+			Answer: Garbage(),
+			Check:  func(err error) bool { return err != nil },
+		},
+		{
+			Name:   "slow",
+			Answer: Slow(time.Second, OK()),
+			Check:  func(err error) bool { return errors.Is(err, context.DeadlineExceeded) },
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			otp := NewOneTimePassword("vvcccccccccc")
+			server.Script(otp, c.Answer)
+			ctx, cancel := context.WithTimeout(t.Context(), time.Millisecond*200)
+			defer cancel()
+			if err := authenticator.Authenticate(ctx, server.Request(otp)); !c.Check(err) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestServerRejectsReplays(t *testing.T) {
+	server := NewServer([]byte("test secret"))
+	t.Cleanup(server.Close)
+	authenticator, err := yubikeyotp.New(
+		yubikeyotp.WithEndpoints(server.URL),
+		yubikeyotp.WithRetryPolicy(yubikeyotp.RetryWithBackOff{
+			AttemptLimit:           1,
+			AttemptDelay:           time.Millisecond * 31,
+			AttemptDelayLimit:      time.Millisecond * 31,
+			AttemptDelayMultiplier: 2,
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otp := NewOneTimePassword("vvcccccccccc")
+	result, err := authenticator.Verify(t.Context(), server.Request(otp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.PublicID != "vvcccccccccc" || result.SyncPercent != 100 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if err = authenticator.Authenticate(t.Context(), server.Request(otp)); !errors.Is(err, yubikeyotp.ErrRequestReplayed) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wrongClient := server.Request(NewOneTimePassword("vvcccccccccc"))
+	wrongClient.ClientID = 2
+	// without the client secret the status cannot be signed
+	if err = authenticator.Authenticate(t.Context(), wrongClient); !errors.Is(err, yubikeyotp.UnverifiedStatusError("NO_SUCH_CLIENT")) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if server.Requests() != 3 {
+		t.Errorf("expected 3 requests, got %d", server.Requests())
+	}
+}